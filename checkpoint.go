@@ -0,0 +1,210 @@
+package nmap
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Checkpoint saves each host to a file at path as soon as nmap finishes
+// scanning it. If the scan is stopped before it finishes, Resume uses the file
+// to only scan the hosts that weren't finished. The file is removed once the
+// scan finishes.
+func (s Scan) Checkpoint(path string) Scan {
+	s.configCheckpoint = path
+	return s
+}
+
+// Resume continues a scan that was stopped while Checkpoint was used. The
+// hosts saved in the checkpoint file are excluded from the scan with
+// `--excludefile`, the rest of the targets are scanned, and the saved hosts
+// are merged into the result. nmap only reports hosts that are up, so hosts
+// that were down are scanned again. When there is no checkpoint file, the whole
+// scan is run.
+func (s Scan) Resume() (Scan, error) {
+	done, err := readCheckpoint(s.configCheckpoint)
+	if os.IsNotExist(err) {
+		return s.Run()
+	}
+	if err != nil {
+		return s, err
+	}
+	if len(done) == 0 {
+		return s.Run()
+	}
+
+	// Completed hosts are added to the hosts that were already excluded
+	var exclude []string
+	opts, removed := withoutFlags(s.configOpts, "--exclude", "--excludefile")
+	for _, f := range removed {
+		if f.option.Name == "--exclude" {
+			exclude = append(exclude, strings.Split(f.arg, ",")...)
+			continue
+		}
+		data, err := ioutil.ReadFile(f.arg)
+		if err != nil {
+			return s, err
+		}
+		exclude = append(exclude, strings.Fields(string(data))...)
+	}
+	for _, host := range done {
+		exclude = append(exclude, host.Address)
+	}
+
+	excludePath := s.configCheckpoint + ".exclude"
+	if err := ioutil.WriteFile(excludePath, []byte(strings.Join(exclude, "\n")+"\n"), 0600); err != nil {
+		return s, err
+	}
+	defer os.Remove(excludePath)
+
+	resumed := s
+	resumed.configOpts = append(opts, "--excludefile", excludePath)
+	output, err := resumed.Run()
+	if err != nil {
+		return output, err
+	}
+
+	// Hosts are rescanned without the completed hosts excluded
+	output.configOpts = s.configOpts
+	config := s.scanConfig()
+	for address, host := range output.Hosts {
+		host.parentScan = config
+		output.Hosts[address] = host
+	}
+	for _, host := range done {
+		if _, ok := output.Hosts[host.Address]; ok {
+			continue
+		}
+		host.parentScan = config
+		output.Hosts[host.Address] = host
+		output.Stats.HostsTotal++
+		if host.State == "up" {
+			output.Stats.HostsUp++
+		} else {
+			output.Stats.HostsDown++
+		}
+	}
+	return output, nil
+}
+
+// readCheckpoint reads the hosts saved in a checkpoint file. A host that was
+// only partly written when the scan was stopped is ignored.
+func readCheckpoint(path string) ([]Host, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if i := bytes.LastIndex(data, []byte("</host>")); i != -1 {
+		data = data[:i+len("</host>")]
+	} else {
+		data = nil
+	}
+
+	raw, err := parseXML(append(append([]byte("<nmaprun>"), data...), "</nmaprun>"...))
+	if err != nil {
+		return nil, err
+	}
+	var hosts []Host
+	for _, host := range raw.Hosts {
+		hosts = append(hosts, host.cleanHost())
+	}
+	return hosts, nil
+}
+
+// checkpointWriter finds each <host> element in nmap's XML output as it is
+// written and appends it to the checkpoint file. nmap writes the start and end
+// of each host on their own lines. Write errors are kept in err instead of
+// being returned, so that nmap's output is still read.
+type checkpointWriter struct {
+	file *os.File
+	line []byte
+	host []byte
+	err  error
+}
+
+// openCheckpoint opens the checkpoint file to add hosts to it
+func openCheckpoint(path string) (*checkpointWriter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpointWriter{file: file}, nil
+}
+
+func (w *checkpointWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i == -1 {
+			break
+		}
+		line := w.line[:i+1]
+		w.line = w.line[i+1:]
+
+		// <host> is matched with a space or `>` so <hostnames> and <hosthint>
+		// aren't mistaken for hosts
+		trimmed := bytes.TrimSpace(line)
+		if bytes.HasPrefix(trimmed, []byte("<host ")) || bytes.HasPrefix(trimmed, []byte("<host>")) {
+			w.host = []byte{}
+		}
+		if w.host == nil {
+			continue
+		}
+		w.host = append(w.host, line...)
+		if bytes.HasPrefix(trimmed, []byte("</host>")) {
+			w.save(w.host)
+			w.host = nil
+		}
+	}
+	return len(p), nil
+}
+
+// save appends one host to the file and syncs it, so the host isn't lost if
+// the machine goes down
+func (w *checkpointWriter) save(host []byte) {
+	if w.err != nil {
+		return
+	}
+	if _, err := w.file.Write(host); err != nil {
+		w.err = err
+		return
+	}
+	w.err = w.file.Sync()
+}
+
+// Close closes the file and returns the first error from writing to it
+func (w *checkpointWriter) Close() error {
+	if err := w.file.Close(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// withoutFlags removes the named flags and their arguments from the options.
+// The removed flags are returned.
+func withoutFlags(opts []string, names ...string) (kept []string, removed []parsedFlag) {
+	for i := 0; i < len(opts); i++ {
+		opt, arg, ok := lookupOption(opts[i])
+		flag := opts[i]
+		consumed := []string{flag}
+		if ok && opt.Arity == 1 && arg == "" && i+1 < len(opts) {
+			i++
+			arg = opts[i]
+			consumed = append(consumed, arg)
+		}
+
+		matched := false
+		for _, name := range names {
+			if ok && opt.Name == name {
+				matched = true
+			}
+		}
+		if matched {
+			removed = append(removed, parsedFlag{opt, flag, arg})
+		} else {
+			kept = append(kept, consumed...)
+		}
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package nmap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// funcRunner runs a function instead of nmap
+type funcRunner func(args []string) (stdout string, exitCode int)
+
+func (f funcRunner) Start(args []string) (Process, error) {
+	stdout, exitCode := f(args)
+	return &replayProcess{strings.NewReader(stdout), strings.NewReader(""), exitCode}, nil
+}
+
+func (f funcRunner) Privileges() Privileges {
+	return Privileges{}
+}
+
+func checkpointHost(address string) string {
+	return `<host starttime="1700000001" endtime="1700000002"><status state="up" reason="syn-ack"/>
+<address addr="` + address + `" addrtype="ipv4"/>
+<hostnames>
+</hostnames>
+<ports><port protocol="tcp" portid="22"><state state="open" reason="syn-ack"/><service name="ssh" method="table"/></port>
+</ports>
+</host>
+`
+}
+
+func TestScan_Resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scan.checkpoint")
+
+	// The first run is stopped after finishing one host and part of another
+	interrupted := funcRunner(func(args []string) (string, int) {
+		return "<nmaprun>\n" + checkpointHost("10.0.0.1") + `<host starttime="1700000003"><status state="up"/>` + "\n", 130
+	})
+	scan := Init().AddHosts("10.0.0.0/30").AddPorts(22).AddFlags("--exclude", "10.0.0.3").Checkpoint(path)
+	if _, err := scan.SetRunner(interrupted).Run(); err == nil {
+		t.Fatal("Interrupted scan should fail")
+	}
+
+	var excluded string
+	resumed := funcRunner(func(args []string) (string, int) {
+		for i, arg := range args {
+			if arg == "--excludefile" {
+				data, _ := ioutil.ReadFile(args[i+1])
+				excluded = string(data)
+			}
+		}
+		return "<nmaprun>\n" + checkpointHost("10.0.0.2") + "</nmaprun>\n", 0
+	})
+	output, err := scan.SetRunner(resumed).Resume()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if excluded != "10.0.0.3\n10.0.0.1\n" {
+		t.Errorf("Expected the finished host to be excluded, got %q", excluded)
+	}
+	for _, address := range []string{"10.0.0.1", "10.0.0.2"} {
+		if host, ok := output.Hosts[address]; !ok || len(host.Ports) != 1 {
+			t.Errorf("Host %s is missing from the resumed scan: %v", address, output.Hosts)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Checkpoint should be removed once the scan finishes")
+	}
+}
@@ -0,0 +1,203 @@
+package nmap
+
+import (
+	"strings"
+	"sync"
+)
+
+// CompositeOptions tunes each protocol's run in RunComposite. The flags are
+// added to the run of their protocol, and replace the scan's flags with the
+// same name, E.x. `-T5` in UDPFlags replaces `-T3` from the scan.
+type CompositeOptions struct {
+	// TCPFlags are used for the TCP run, E.x. `-T4 --min-rate 1000`
+	TCPFlags []string
+	// UDPFlags are used for the UDP run, E.x. `-T4 --max-retries 1`
+	UDPFlags []string
+}
+
+// ProtocolError is returned by RunComposite when the run of a protocol fails
+type ProtocolError struct {
+	Protocol string
+	Err      error
+}
+
+// Error returns the protocol along with its error
+func (p *ProtocolError) Error() string {
+	return "The " + p.Protocol + " scan failed: " + p.Err.Error()
+}
+
+// Unwrap returns the protocol's error
+func (p *ProtocolError) Unwrap() error {
+	return p.Err
+}
+
+// protocolScan is the scan of one protocol of a composite plan
+type protocolScan struct {
+	protocol string
+	scan     Scan
+}
+
+// SplitProtocols splits the scan into a TCP scan and a UDP scan. Ports given
+// to AddPorts are scanned by both when the scan uses UDP, the same as nmap
+// does. The TCP scan doesn't use `-sU`, and the UDP scan doesn't use a TCP scan
+// type. When the scan only uses one protocol, or it is a ping or list scan,
+// only one scan is returned.
+func (s Scan) SplitProtocols(opts CompositeOptions) []Scan {
+	var scans []Scan
+	for _, p := range s.protocolScans(opts) {
+		scans = append(scans, p.scan)
+	}
+	return scans
+}
+
+// protocolScans splits the scan into the scan of each protocol it uses. The
+// protocols are found the same way CreateNmapArgs picks scan types.
+func (s Scan) protocolScans(opts CompositeOptions) []protocolScan {
+	flags, _ := parseFlags(s.configOpts)
+	mode := scanMode(flags)
+	if mode != "" && mode != modePortScan {
+		return []protocolScan{{"", s}}
+	}
+
+	tcp := hasGroup(flags, groupTCPScan) || len(s.configTCPPorts) != 0 ||
+		(mode == "" && (len(s.configPorts) != 0 || len(s.configUDPPorts) == 0))
+	udp := hasFlag(flags, "-sU") || len(s.configUDPPorts) != 0
+	if !udp {
+		return []protocolScan{{"TCP", s.withProtocolFlags(opts.TCPFlags)}}
+	}
+	if !tcp {
+		return []protocolScan{{"UDP", s.withProtocolFlags(opts.UDPFlags)}}
+	}
+
+	tcpScan := s.withProtocolFlags(opts.TCPFlags)
+	tcpScan.configOpts, _ = withoutFlags(tcpScan.configOpts, "-sU")
+	tcpScan = tcpScan.
+		SetPorts().
+		SetTCPPorts(append(append([]uint16{}, s.configPorts...), s.configTCPPorts...)...).
+		SetUDPPorts()
+
+	udpScan := s.withProtocolFlags(opts.UDPFlags)
+	var tcpTypes []string
+	for _, f := range flags {
+		if f.option.Group == groupTCPScan {
+			tcpTypes = append(tcpTypes, f.option.Name)
+		}
+	}
+	udpScan.configOpts, _ = withoutFlags(udpScan.configOpts, tcpTypes...)
+	udpScan = udpScan.
+		SetPorts().
+		SetTCPPorts().
+		SetUDPPorts(append(append([]uint16{}, s.configPorts...), s.configUDPPorts...)...)
+	if len(udpScan.configUDPPorts) == 0 {
+		// Without ports nmap scans its default UDP ports, but only with `-sU`
+		udpScan = udpScan.AddFlags("-sU")
+	}
+
+	return []protocolScan{{"TCP", tcpScan}, {"UDP", udpScan}}
+}
+
+// withProtocolFlags adds flags to the scan, replacing the scan's flags with the
+// same name
+func (s Scan) withProtocolFlags(flags []string) Scan {
+	if len(flags) == 0 {
+		return s
+	}
+	parsed, _ := parseFlags(flags)
+	var names []string
+	for _, f := range parsed {
+		names = append(names, f.option.Name)
+	}
+	s.configOpts, _ = withoutFlags(s.configOpts, names...)
+	return s.AddFlags(flags...)
+}
+
+// RunComposite runs the TCP and UDP ports of the scan as separate nmap
+// processes at the same time, and merges their results with MergeScans. UDP
+// scans are much slower than TCP scans, so this returns TCP results as fast as
+// possible and lets each protocol use its own timing:
+//
+//	output, err := nmap.Init().
+//		AddHosts("10.0.0.0/24").
+//		AddTCPPorts(22, 80, 443).
+//		AddUDPPorts(53, 161).
+//		RunComposite(nmap.CompositeOptions{
+//			TCPFlags: []string{"-T4"},
+//			UDPFlags: []string{"-T4", "--max-retries", "1"},
+//		})
+//
+// See SplitProtocols for how the scan is split. A scan that only uses one
+// protocol is run with Run. When Record is used, each run is saved to its own
+// archive with the protocol added to the path, E.x. `scan.tgz` becomes
+// `scan-tcp.tgz`. A composite scan can't be resumed, since a host is only done
+// once both runs finish with it, so Checkpoint isn't used when the scan is
+// split.
+//
+// The scan is validated before it is split, so an invalid scan returns the
+// ConfigErrors from Validate. When a run fails, the results of the other run
+// are returned along with a ProtocolError. The result keeps the scan's
+// configuration, so it can be run again.
+func (s Scan) RunComposite(opts CompositeOptions) (Scan, error) {
+	if err := s.Validate(); err != nil {
+		return s, err
+	}
+
+	plans := s.protocolScans(opts)
+	if len(plans) == 1 {
+		return plans[0].scan.Run()
+	}
+
+	scans := make([]Scan, len(plans))
+	for i, plan := range plans {
+		scans[i] = plan.scan
+		scans[i].configCheckpoint = ""
+		if s.configRecordPath != "" {
+			scans[i].configRecordPath = suffixRecordPath(s.configRecordPath, strings.ToLower(plan.protocol))
+		}
+	}
+	serializeCallbacks(scans)
+
+	results := make([]Scan, len(scans))
+	errs := make([]error, len(scans))
+	var wg sync.WaitGroup
+	for i := range scans {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = scans[i].run()
+		}(i)
+	}
+	wg.Wait()
+
+	var done []Scan
+	var failed error
+	for i, err := range errs {
+		if err != nil {
+			if failed == nil {
+				failed = &ProtocolError{plans[i].protocol, err}
+			}
+			continue
+		}
+		done = append(done, results[i])
+	}
+	return mergeProtocols(s, done), failed
+}
+
+// mergeProtocols merges the runs of each protocol with MergeScans, keeping s as
+// the base so the result has the scan's configuration and can be run again.
+// Hosts are rescanned with both protocols.
+func mergeProtocols(s Scan, runs []Scan) Scan {
+	merged := MergeScans(runs...)
+	output := s
+	output.DisplayArgs = merged.DisplayArgs
+	output.NmapVersion = merged.NmapVersion
+	output.Warnings = merged.Warnings
+	output.Stats = merged.Stats
+
+	output.Hosts = make(map[string]Host, len(merged.Hosts))
+	config := s.scanConfig()
+	for address, host := range merged.Hosts {
+		host.parentScan = config
+		output.Hosts[address] = host
+	}
+	return output
+}
@@ -0,0 +1,123 @@
+package nmap
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestScan_SplitProtocols(t *testing.T) {
+	scan := Init().AddHosts("10.0.0.1").AddPorts(53).AddTCPPorts(22).AddUDPPorts(161).AddFlags("-T3", "-sS").SetPrivileges(Privileges{Root: true})
+	scans := scan.SplitProtocols(CompositeOptions{UDPFlags: []string{"-T5", "--max-retries", "1"}})
+	if len(scans) != 2 {
+		t.Fatalf("Expected a TCP and a UDP scan, got %d", len(scans))
+	}
+
+	tcp, err := scans[0].CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tcp, " "); got != "-oX - -T3 -sS -pT:53,22 10.0.0.1" {
+		t.Errorf("Unexpected TCP args %q", got)
+	}
+
+	udp, err := scans[1].CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(udp, " "); got != "-oX - -T5 --max-retries 1 -sU -pU:53,161 10.0.0.1" {
+		t.Errorf("Unexpected UDP args %q", got)
+	}
+}
+
+func TestScan_SplitProtocols_oneProtocol(t *testing.T) {
+	scans := Init().AddHosts("10.0.0.1").AddPorts(22).SplitProtocols(CompositeOptions{TCPFlags: []string{"-T4"}})
+	if len(scans) != 1 || len(scans[0].configOpts) != 1 || scans[0].configOpts[0] != "-T4" {
+		t.Errorf("Expected only a TCP scan with its flags, got %v", scans)
+	}
+
+	scans = Init().AddHosts("10.0.0.1").AddFlags("-sU").SplitProtocols(CompositeOptions{})
+	if len(scans) != 1 {
+		t.Errorf("Expected only a UDP scan, got %d scans", len(scans))
+	}
+}
+
+func TestScan_RunComposite(t *testing.T) {
+	runner := funcRunner(func(args []string) (string, int) {
+		joined := strings.Join(args, " ")
+		port := `<port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>`
+		if strings.Contains(joined, "-sU") {
+			port = `<port protocol="udp" portid="161"><state state="open|filtered"/><service name="snmp"/></port>`
+		}
+		return `<nmaprun args="nmap ` + joined + `"><host><status state="up"/><address addr="10.0.0.1" addrtype="ipv4"/><ports>` +
+			port + `</ports></host></nmaprun>`, 0
+	})
+
+	output, err := Init().AddHosts("10.0.0.1").AddTCPPorts(22).AddUDPPorts(161).SetRunner(runner).SetPrivileges(Privileges{Root: true}).RunComposite(CompositeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := output.Hosts["10.0.0.1"]
+	if len(host.Ports) != 2 || host.Ports[0].Protocol != "tcp" || host.Ports[1].Protocol != "udp" {
+		t.Errorf("Expected both runs to be merged, got %v", host.Ports)
+	}
+}
+
+func TestScan_RunComposite_rerun(t *testing.T) {
+	var runs []string
+	var mu sync.Mutex
+	runner := funcRunner(func(args []string) (string, int) {
+		joined := strings.Join(args, " ")
+		mu.Lock()
+		runs = append(runs, joined)
+		mu.Unlock()
+		return `<nmaprun args="nmap ` + joined + `"><host><status state="up"/><address addr="10.0.0.1" addrtype="ipv4"/></host></nmaprun>`, 0
+	})
+
+	scan := Init().AddHosts("10.0.0.1").AddTCPPorts(22).AddUDPPorts(161).SetRunner(runner).SetPrivileges(Privileges{Root: true})
+	output, err := scan.RunComposite(CompositeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := append([]string{}, runs...)
+	runs = nil
+	if _, err := output.RunComposite(CompositeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(first)
+	sort.Strings(runs)
+	if len(runs) != 2 || strings.Join(runs, "|") != strings.Join(first, "|") {
+		t.Errorf("Rerunning the result should run the same scans:\n%q\n%q", first, runs)
+	}
+	if host := output.Hosts["10.0.0.1"]; host.parentScan == nil || len(host.parentScan.configUDPPorts) != 1 {
+		t.Errorf("Hosts should be rescanned with both protocols")
+	}
+}
+
+func TestScan_RunComposite_failed(t *testing.T) {
+	runner := funcRunner(func(args []string) (string, int) {
+		if strings.Contains(strings.Join(args, " "), "-sU") {
+			return "", 1
+		}
+		return `<nmaprun><host><status state="up"/><address addr="10.0.0.1" addrtype="ipv4"/><ports>` +
+			`<port protocol="tcp" portid="22"><state state="open"/></port></ports></host></nmaprun>`, 0
+	})
+
+	output, err := Init().AddHosts("10.0.0.1").AddTCPPorts(22).AddUDPPorts(161).SetRunner(runner).SetPrivileges(Privileges{Root: true}).RunComposite(CompositeOptions{})
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || protocolErr.Protocol != "UDP" {
+		t.Fatalf("Expected a ProtocolError for UDP, got %v", err)
+	}
+	if _, ok := output.Hosts["10.0.0.1"]; !ok {
+		t.Error("Expected the TCP results to be kept")
+	}
+}
+
+func TestScan_RunComposite_invalid(t *testing.T) {
+	_, err := Init().AddTCPPorts(22).AddUDPPorts(161).SetPrivileges(Privileges{Root: true}).RunComposite(CompositeOptions{})
+	if _, ok := err.(ConfigErrors); !ok {
+		t.Errorf("Expected the ConfigErrors from Validate, got %v", err)
+	}
+}
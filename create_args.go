@@ -1,17 +1,18 @@
 package nmap
 
 import (
-	"errors"
-	"fmt"
 	"strconv"
 	"strings"
-
-	"github.com/t94j0/array"
 )
 
 // CreateNmapArgs takes a Scan object and returns a list of strings that map to
-// arguments for an nmap scan.
+// arguments for an nmap scan. The Scan is validated first, and the errors from
+// Validate are returned if there are any.
 func (s Scan) CreateNmapArgs() ([]string, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Parse arguments
 	args := []string{"-oX", "-"}
 	const seperator string = ","
@@ -34,33 +35,43 @@ func (s Scan) CreateNmapArgs() ([]string, error) {
 	}
 	portList += strings.Join(uint16ListToStringList(s.configTCPPorts), seperator)
 
-	// Check to make sure all TCP/UDP flags are correct
-	// Check TCP flags
-	tcpOptions := []string{"-sS", "-sT", "-sA", "-sW", "-sM"}
-	iflag := array.Intersection(tcpOptions, s.configOpts).([]string)
-	if len(iflag) == 0 {
-		s.configOpts = append(s.configOpts, tcpOptions[1])
+	// Only add scan types that fit with the chosen scan mode. A ping or list
+	// scan doesn't scan ports, and a UDP-only scan shouldn't also scan TCP.
+	flags, _ := parseFlags(s.configOpts)
+	mode := scanMode(flags)
+
+	// configOpts can share its backing array with other Scans, such as the
+	// shards of RunParallel, so scan types are appended to a copy
+	s.configOpts = append([]string{}, s.configOpts...)
+
+	// Check TCP flags. The best scan type for the privileges is used
+	tcpScan, privilegeMode := s.privilegeArgs(flags)
+	if !hasGroup(flags, groupTCPScan) && (mode == "" || mode == modePortScan) {
+		needsTCP := len(s.configTCPPorts) != 0 ||
+			(mode == "" && (len(s.configPorts) != 0 || len(s.configUDPPorts) == 0))
+		if needsTCP {
+			s.configOpts = append(s.configOpts, tcpScan)
+		}
 	}
 
 	// Check UDP flag
-	if len(s.configUDPPorts) != 0 && !array.In("-sU", s.configOpts) {
+	if len(s.configUDPPorts) != 0 && !hasFlag(flags, "-sU") {
 		s.configOpts = append(s.configOpts, "-sU")
 	}
 
 	// Append arguments
+	args = append(args, privilegeMode...)
 	args = append(args, s.configOpts...)
+	args = append(args, s.scriptArgs()...)
+	args = append(args, s.progressArgs(flags)...)
 
 	// Append port list
 	if portList != "" {
 		args = append(args, "-p"+portList)
 	}
 	// Append hosts
-	if len(s.configHosts) == 0 {
-		s.configErr = errors.New("No hosts added")
-	}
 	args = append(args, s.configHosts...)
 
-	fmt.Println(args)
 	return args, nil
 }
 
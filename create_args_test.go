@@ -0,0 +1,132 @@
+package nmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/t94j0/array"
+)
+
+var (
+	unprivileged = Privileges{}
+	root         = Privileges{Root: true}
+)
+
+func TestScan_CreateNmapArgs_defaulttcp(t *testing.T) {
+	args, err := Init().AddHosts("localhost").AddPorts(80).SetPrivileges(unprivileged).CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !array.In("-sT", args) || !array.In("--unprivileged", args) {
+		t.Errorf("-sT should be added when no scan type is given without root: %v", args)
+	}
+
+	args, err = Init().AddHosts("localhost").AddPorts(80).SetPrivileges(root).CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !array.In("-sS", args) || array.In("--privileged", args) {
+		t.Errorf("-sS should be added when no scan type is given as root: %v", args)
+	}
+
+	capabilities := Privileges{NetRaw: true, NetAdmin: true}
+	args, err = Init().AddHosts("localhost").SetPrivileges(capabilities).CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !array.In("-sS", args) || !array.In("--privileged", args) {
+		t.Errorf("--privileged should be used with capabilities: %v", args)
+	}
+}
+
+func TestScan_Validate_privileges(t *testing.T) {
+	err := Init().AddHosts("localhost").AddFlags("-sS", "-O").AddUDPPorts(53).SetPrivileges(unprivileged).Validate()
+	var privilegeErr *PrivilegeError
+	if !errors.As(err, &privilegeErr) {
+		t.Fatalf("Expected a PrivilegeError, got %v", err)
+	}
+	if strings.Join(privilegeErr.Flags, " ") != "-sS -O -sU" {
+		t.Errorf("Wrong flags reported: %v", privilegeErr.Flags)
+	}
+
+	err = Init().AddHosts("localhost").AddFlags("-sS", "--privileged").SetPrivileges(unprivileged).Validate()
+	if err != nil {
+		t.Errorf("--privileged should skip the privilege check: %s", err)
+	}
+}
+
+func TestScan_CreateNmapArgs_ping(t *testing.T) {
+	args, err := Init().AddHosts("localhost").Ping().CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if array.In("-sT", args) {
+		t.Errorf("-sT should not be added to a ping scan: %v", args)
+	}
+}
+
+func TestScan_CreateNmapArgs_udponly(t *testing.T) {
+	args, err := Init().AddHosts("localhost").AddUDPPorts(53).SetPrivileges(root).CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if array.In("-sT", args) {
+		t.Errorf("-sT should not be added to a UDP-only scan: %v", args)
+	}
+	if !array.In("-sU", args) {
+		t.Errorf("-sU should be added when UDP ports are given: %v", args)
+	}
+
+	args, err = Init().AddHosts("localhost").AddFlags("-sU").SetPrivileges(root).CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if array.In("-sT", args) {
+		t.Errorf("-sT should not be added when -sU is used alone: %v", args)
+	}
+}
+
+func TestScan_Validate_arguments(t *testing.T) {
+	scan := Init().AddHosts("localhost").AddFlags("--max-retries", "2", "-p80,443", "-T4")
+	if err := scan.Validate(); err != nil {
+		t.Errorf("Valid flags were rejected: %s", err)
+	}
+
+	scan = Init().AddHosts("localhost").AddFlags("-sV", "2")
+	if err := scan.Validate(); err == nil {
+		t.Errorf("Argument to a flag that takes no arguments was accepted")
+	}
+
+	scan = Init().AddHosts("localhost").AddFlags("--max-retries")
+	if err := scan.Validate(); err == nil {
+		t.Errorf("Flag missing its argument was accepted")
+	}
+
+	scan = Init().AddHosts("localhost").AddFlags("--not-a-flag")
+	if err := scan.Validate(); err == nil {
+		t.Errorf("Unknown flag was accepted")
+	}
+}
+
+func TestScan_Validate_conflicts(t *testing.T) {
+	scan := Init().AddHosts("localhost").AddFlags("-sS", "-sT")
+	if err := scan.Validate(); err == nil {
+		t.Errorf("Two TCP scan types were accepted")
+	}
+
+	scan = Init().AddHosts("localhost").AddFlags("-sn", "-sS")
+	if err := scan.Validate(); err == nil {
+		t.Errorf("Ping scan with a port scan was accepted")
+	}
+
+	scan = Init().AddHosts("localhost").AddPorts(80).Ping()
+	if err := scan.Validate(); err == nil {
+		t.Errorf("Ping scan with ports was accepted")
+	}
+
+	scan = Init().AddHosts("localhost").AddFlags("-sS", "-sU").SetPrivileges(root)
+	if err := scan.Validate(); err != nil {
+		t.Errorf("TCP and UDP scan types were rejected: %s", err)
+	}
+}
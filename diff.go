@@ -0,0 +1,221 @@
+package nmap
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Fields that are compared by DiffScans
+const (
+	FieldState     = "state"
+	FieldHostnames = "hostnames"
+	FieldService   = "service"
+	FieldProduct   = "product"
+	FieldVersion   = "version"
+	FieldExtraInfo = "extrainfo"
+	// Script changes use FieldScript followed by the script name, E.x.
+	// `script:http-title`
+	FieldScript = "script:"
+)
+
+// Change is one field that is different between two scans. Old or New is
+// empty when the field was only in one of the scans.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// PortDiff is the changes to one port of a host
+type PortDiff struct {
+	Protocol string
+	ID       uint32
+	// Old and New are the port in each scan. One of them is nil when the port
+	// was only in one scan.
+	Old *Port
+	New *Port
+	// Changes are the fields that are different, in the order of the Field
+	// constants
+	Changes []Change
+}
+
+// HostDiff is the changes to one host that is in both scans
+type HostDiff struct {
+	Address string
+	Old     Host
+	New     Host
+	// Changes are the changes to the host itself, E.x. its state
+	Changes []Change
+	// Ports are the ports that changed, sorted by protocol and port number
+	Ports []PortDiff
+}
+
+// ScanDiff is the difference between two scans
+type ScanDiff struct {
+	// Old and New are the scans that were compared
+	Old Scan
+	New Scan
+	// Added are the hosts that are only in the new scan
+	Added []Host
+	// Removed are the hosts that are only in the old scan
+	Removed []Host
+	// Changed are the hosts in both scans that changed
+	Changed []HostDiff
+}
+
+// Empty returns true when nothing changed between the scans
+func (d ScanDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffScans finds every change between two scans. Hosts are matched by
+// address and ports are matched by protocol and port number, so a port whose
+// service or script output changed is reported as one change instead of a
+// removed and an added port. Hosts and ports are sorted so that the diff is
+// always in the same order.
+func DiffScans(old, new Scan) ScanDiff {
+	diff := ScanDiff{Old: old, New: new}
+
+	for _, address := range sortedAddresses(new.Hosts) {
+		newHost := new.Hosts[address]
+		oldHost, ok := old.Hosts[address]
+		if !ok {
+			diff.Added = append(diff.Added, newHost)
+			continue
+		}
+		if hostDiff := diffHosts(oldHost, newHost); len(hostDiff.Changes) != 0 || len(hostDiff.Ports) != 0 {
+			diff.Changed = append(diff.Changed, hostDiff)
+		}
+	}
+	for _, address := range sortedAddresses(old.Hosts) {
+		if _, ok := new.Hosts[address]; !ok {
+			diff.Removed = append(diff.Removed, old.Hosts[address])
+		}
+	}
+
+	return diff
+}
+
+// diffHosts compares two scans of the same host
+func diffHosts(old, new Host) HostDiff {
+	diff := HostDiff{Address: new.Address, Old: old, New: new}
+	diff.Changes = appendChange(diff.Changes, FieldState, old.State, new.State)
+	diff.Changes = appendChange(diff.Changes, FieldHostnames, hostnameList(old), hostnameList(new))
+
+	oldPorts := old.portMap()
+	newPorts := new.portMap()
+	keys := make([]PortKey, 0, len(oldPorts)+len(newPorts))
+	for key := range newPorts {
+		keys = append(keys, key)
+	}
+	for key := range oldPorts {
+		if _, ok := newPorts[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return portKeyLess(keys[i], keys[j]) })
+
+	for _, key := range keys {
+		portDiff := PortDiff{Protocol: key.Protocol, ID: key.ID}
+		var oldPort, newPort Port
+		if port, ok := oldPorts[key]; ok {
+			oldPort = port
+			portDiff.Old = &port
+		}
+		if port, ok := newPorts[key]; ok {
+			newPort = port
+			portDiff.New = &port
+		}
+		portDiff.Changes = diffPorts(oldPort, newPort)
+		if len(portDiff.Changes) != 0 {
+			diff.Ports = append(diff.Ports, portDiff)
+		}
+	}
+
+	return diff
+}
+
+// diffPorts compares two scans of the same port. A port that is missing from
+// a scan is the zero Port.
+func diffPorts(old, new Port) (changes []Change) {
+	changes = appendChange(changes, FieldState, old.State, new.State)
+	changes = appendChange(changes, FieldService, old.Service, new.Service)
+	changes = appendChange(changes, FieldProduct, old.Product, new.Product)
+	changes = appendChange(changes, FieldVersion, old.Version, new.Version)
+	changes = appendChange(changes, FieldExtraInfo, old.ExtraInfo, new.ExtraInfo)
+
+	oldScripts := make(map[string]string)
+	for _, script := range old.Scripts {
+		oldScripts[script.Name] = script.Output
+	}
+	newScripts := make(map[string]string)
+	var names []string
+	for _, script := range new.Scripts {
+		newScripts[script.Name] = script.Output
+		names = append(names, script.Name)
+	}
+	for _, script := range old.Scripts {
+		if _, ok := newScripts[script.Name]; !ok {
+			names = append(names, script.Name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		changes = appendChange(changes, FieldScript+name, oldScripts[name], newScripts[name])
+	}
+	return
+}
+
+// appendChange adds a Change when the values are different
+func appendChange(changes []Change, field, old, new string) []Change {
+	if old == new {
+		return changes
+	}
+	return append(changes, Change{field, old, new})
+}
+
+// hostnameList joins the names of a host's hostnames
+func hostnameList(host Host) string {
+	names := make([]string, 0, len(host.Hostnames))
+	for _, hostname := range host.Hostnames {
+		names = append(names, hostname.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+// sortedAddresses returns the addresses of the hosts sorted by IP. IPv4
+// addresses come before IPv6 addresses, and anything that isn't an IP comes
+// last.
+func sortedAddresses(hosts map[string]Host) []string {
+	addresses := make([]string, 0, len(hosts))
+	for address := range hosts {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addressLess(addresses[i], addresses[j])
+	})
+	return addresses
+}
+
+// addressLess compares two addresses by IP
+func addressLess(a, b string) bool {
+	aIP, bIP := net.ParseIP(a), net.ParseIP(b)
+	if aIP == nil || bIP == nil {
+		if aIP == nil && bIP == nil {
+			return a < b
+		}
+		return aIP != nil
+	}
+
+	a4, b4 := aIP.To4(), bIP.To4()
+	if (a4 == nil) != (b4 == nil) {
+		return a4 != nil
+	}
+	if a4 != nil {
+		return bytes.Compare(a4, b4) < 0
+	}
+	return bytes.Compare(aIP, bIP) < 0
+}
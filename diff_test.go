@@ -0,0 +1,77 @@
+package nmap
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiffScans(t *testing.T) {
+	old := Init()
+	old.Hosts["10.0.0.1"] = Host{Address: "10.0.0.1", State: "up", Ports: []Port{
+		{Protocol: "tcp", ID: 22, State: "open", Service: "ssh", Product: "OpenSSH", Version: "7.4"},
+		{Protocol: "tcp", ID: 80, State: "closed", Service: "http"},
+		{Protocol: "tcp", ID: 443, State: "open", Service: "https"},
+	}}
+	old.Hosts["10.0.0.9"] = Host{Address: "10.0.0.9", State: "up"}
+
+	new := Init()
+	new.Hosts["10.0.0.1"] = Host{Address: "10.0.0.1", State: "up", Ports: []Port{
+		{Protocol: "tcp", ID: 22, State: "open", Service: "ssh", Product: "OpenSSH", Version: "8.0"},
+		{Protocol: "tcp", ID: 80, State: "open", Service: "http",
+			Scripts: []Script{{Name: "http-title", Output: "Welcome"}}},
+		{Protocol: "udp", ID: 443, State: "open", Service: "https"},
+	}}
+	new.Hosts["10.0.0.10"] = Host{Address: "10.0.0.10", State: "up"}
+	new.Hosts["10.0.0.2"] = Host{Address: "10.0.0.2", State: "up"}
+
+	diff := DiffScans(old, new)
+	if len(diff.Added) != 2 || diff.Added[0].Address != "10.0.0.2" || diff.Added[1].Address != "10.0.0.10" {
+		t.Errorf("Expected 10.0.0.2 and 10.0.0.10 to be added in order, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Address != "10.0.0.9" {
+		t.Errorf("Expected 10.0.0.9 to be removed, got %v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("Expected one changed host, got %v", diff.Changed)
+	}
+
+	var got []string
+	for _, port := range diff.Changed[0].Ports {
+		for _, change := range port.Changes {
+			got = append(got, fmt.Sprintf("%d/%s %s %q->%q", port.ID, port.Protocol, change.Field, change.Old, change.New))
+		}
+	}
+	expected := []string{
+		`22/tcp version "7.4"->"8.0"`,
+		`80/tcp state "closed"->"open"`,
+		`80/tcp script:http-title ""->"Welcome"`,
+		`443/tcp state "open"->""`,
+		`443/tcp service "https"->""`,
+		`443/udp state ""->"open"`,
+		`443/udp service ""->"https"`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected changes:\n%q\ngot:\n%q", expected, got)
+	}
+	if port := diff.Changed[0].Ports[2]; port.Old == nil || port.New != nil {
+		t.Errorf("Removed port should only have the old port: %+v", port)
+	}
+}
+
+func TestRawService_version(t *testing.T) {
+	raw, err := parseXML([]byte(`<nmaprun><host><address addr="10.0.0.1" addrtype="ipv4"/><ports>
+<port protocol="tcp" portid="22"><state state="open"/><service name="ssh" product="OpenSSH" version="6.6.1p1" extrainfo="protocol 2.0" method="probed"><cpe>cpe:/a:openbsd:openssh:6.6.1p1</cpe><cpe>cpe:/o:linux:linux_kernel</cpe></service>
+<script id="ssh-hostkey" output="..."><elem key="type">ssh-dss</elem></script></port>
+</ports></host></nmaprun>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := raw.Hosts[0].cleanHost().Ports[0]
+	if port.Product != "OpenSSH" || port.Version != "6.6.1p1" || port.ExtraInfo != "protocol 2.0" || len(port.CPEs) != 2 {
+		t.Errorf("Service version was not parsed: %+v", port)
+	}
+	if elem := port.Scripts[0].Elements[0]; elem.Key != "type" || elem.Value != "ssh-dss" {
+		t.Errorf("Script element was not parsed: %+v", elem)
+	}
+}
@@ -0,0 +1,212 @@
+package nmap
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DataFiles are the files in nmap's data directory that scans depend on
+var DataFiles = []string{
+	"nmap-services",
+	"nmap-service-probes",
+	"nmap-os-db",
+	filepath.Join("scripts", "script.db"),
+}
+
+// FileCheck is whether one of nmap's data files was found
+type FileCheck struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Found bool   `json:"found"`
+}
+
+// InterfaceCheck is a network interface that nmap can scan from
+type InterfaceCheck struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+	Up        bool     `json:"up"`
+	Loopback  bool     `json:"loopback"`
+}
+
+// EnvironmentReport is the result of CheckEnvironment. Problems lists
+// everything that will stop scans from working, so an empty list means the
+// environment is ready.
+type EnvironmentReport struct {
+	NmapPath   string           `json:"nmap_path"`
+	NmapInfo   *NmapInfo        `json:"nmap_info,omitempty"`
+	Privileges Privileges       `json:"privileges"`
+	DataDir    string           `json:"data_dir"`
+	DataFiles  []FileCheck      `json:"data_files"`
+	Interfaces []InterfaceCheck `json:"interfaces"`
+	Problems   []string         `json:"problems"`
+	Warnings   []string         `json:"warnings"`
+}
+
+// OK returns true when no problems were found
+func (r *EnvironmentReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// CheckEnvironment checks that nmap is installed and usable. It finds the
+// nmap binary and version, the privileges nmap will have, nmap's data files
+// and the network interfaces that can be scanned from.
+func CheckEnvironment() *EnvironmentReport {
+	return Init().CheckEnvironment()
+}
+
+// CheckEnvironment checks the environment like the CheckEnvironment function,
+// but runs `nmap --version` with the scan's Runner, reports the scan's
+// privileges and finds the data directory with DataDir. Data files and
+// interfaces are always checked on this host.
+func (s Scan) CheckEnvironment() *EnvironmentReport {
+	report := &EnvironmentReport{}
+
+	runner := s.runner()
+	if execRunner, ok := runner.(ExecRunner); ok && len(execRunner.Prefix) == 0 {
+		report.NmapPath = s.nmapPath()
+		if report.NmapPath == "" {
+			report.Problems = append(report.Problems, ErrNmapNotFound.Error())
+			runner = nil
+		}
+	}
+
+	if runner != nil {
+		info, err := DetectNmapInfo(runner)
+		if err != nil {
+			report.Problems = append(report.Problems, "Could not run `nmap --version`: "+err.Error())
+		} else {
+			report.NmapInfo = info
+			if !info.NSE() {
+				report.Warnings = append(report.Warnings, "nmap was built without Lua, so NSE scripts can't be run")
+			}
+			if !info.OpenSSL() {
+				report.Warnings = append(report.Warnings, "nmap was built without OpenSSL, so SSL services can't be detected")
+			}
+		}
+	}
+
+	report.Privileges = s.Privileges()
+	if !report.Privileges.Privileged() {
+		report.Warnings = append(report.Warnings,
+			"nmap is not privileged, so only TCP connect scans can be run (run as root or give nmap the CAP_NET_RAW and CAP_NET_ADMIN capabilities)")
+	}
+
+	report.checkDataDir(s.DataDir())
+	report.checkInterfaces()
+
+	return report
+}
+
+// Doctor checks the environment like CheckEnvironment, and returns an error
+// listing the problems if there are any
+func Doctor() (*EnvironmentReport, error) {
+	return Init().Doctor()
+}
+
+// Doctor checks the environment with the scan's Runner like
+// Scan.CheckEnvironment, and returns an error listing the problems if there
+// are any
+func (s Scan) Doctor() (*EnvironmentReport, error) {
+	report := s.CheckEnvironment()
+	if !report.OK() {
+		return report, fmt.Errorf("nmap can't be used:\n%s", strings.Join(report.Problems, "\n"))
+	}
+	return report, nil
+}
+
+// checkDataDir checks the data directory for each of DataFiles
+func (r *EnvironmentReport) checkDataDir(dir string, err error) {
+	if err != nil {
+		r.Problems = append(r.Problems, err.Error())
+		return
+	}
+	r.DataDir = dir
+
+	for _, name := range DataFiles {
+		check := FileCheck{Name: name, Path: filepath.Join(dir, name)}
+		if _, err := os.Stat(check.Path); err == nil {
+			check.Found = true
+		} else {
+			r.Problems = append(r.Problems, "nmap data file "+name+" is missing from "+dir)
+		}
+		r.DataFiles = append(r.DataFiles, check)
+	}
+}
+
+// checkInterfaces lists the network interfaces and their addresses
+func (r *EnvironmentReport) checkInterfaces() {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		r.Problems = append(r.Problems, "Could not list network interfaces: "+err.Error())
+		return
+	}
+
+	usable := false
+	for _, iface := range interfaces {
+		check := InterfaceCheck{
+			Name:     iface.Name,
+			Up:       iface.Flags&net.FlagUp != 0,
+			Loopback: iface.Flags&net.FlagLoopback != 0,
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			check.Addresses = append(check.Addresses, addr.String())
+		}
+		if check.Up && !check.Loopback && len(check.Addresses) != 0 {
+			usable = true
+		}
+		r.Interfaces = append(r.Interfaces, check)
+	}
+
+	if !usable {
+		r.Warnings = append(r.Warnings, "No network interface is up with an address, so only localhost can be scanned")
+	}
+}
+
+// String formats the report for printing
+func (r *EnvironmentReport) String() (out string) {
+	status := func(ok bool) string {
+		if ok {
+			return "ok"
+		}
+		return "MISSING"
+	}
+
+	out += fmt.Sprintf("nmap:       %s\n", orNone(r.NmapPath))
+	if r.NmapInfo != nil {
+		out += fmt.Sprintf("version:    %s (%s)\n", r.NmapInfo.Version, r.NmapInfo.Platform)
+		out += fmt.Sprintf("libraries:  %s\n", orNone(strings.Join(r.NmapInfo.CompiledWith, " ")))
+	}
+	out += fmt.Sprintf("privileged: %t (root: %t, CAP_NET_RAW: %t, CAP_NET_ADMIN: %t)\n",
+		r.Privileges.Privileged(), r.Privileges.Root, r.Privileges.NetRaw, r.Privileges.NetAdmin)
+	out += fmt.Sprintf("data dir:   %s\n", orNone(r.DataDir))
+	for _, file := range r.DataFiles {
+		out += fmt.Sprintf("  %-22s %s\n", file.Name, status(file.Found))
+	}
+	out += "interfaces:\n"
+	for _, iface := range r.Interfaces {
+		state := "down"
+		if iface.Up {
+			state = "up"
+		}
+		out += fmt.Sprintf("  %-10s %-4s %s\n", iface.Name, state, strings.Join(iface.Addresses, ", "))
+	}
+	for _, warning := range r.Warnings {
+		out += "warning: " + warning + "\n"
+	}
+	for _, problem := range r.Problems {
+		out += "problem: " + problem + "\n"
+	}
+	return
+}
+
+// orNone returns "none" for empty strings
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
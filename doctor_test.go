@@ -0,0 +1,66 @@
+package nmap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentReport_checkDataDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nmapdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "nmap-services"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	old, set := os.LookupEnv("NMAPDIR")
+	os.Setenv("NMAPDIR", dir)
+	defer func() {
+		if set {
+			os.Setenv("NMAPDIR", old)
+		} else {
+			os.Unsetenv("NMAPDIR")
+		}
+	}()
+
+	report := &EnvironmentReport{}
+	report.checkDataDir(FindDataDir())
+	if report.DataDir != filepath.Clean(dir) {
+		t.Errorf("Expected data dir %s, got %s", dir, report.DataDir)
+	}
+	if len(report.DataFiles) != len(DataFiles) || !report.DataFiles[0].Found || report.DataFiles[1].Found {
+		t.Errorf("Data files were not checked: %+v", report.DataFiles)
+	}
+	if report.OK() || !strings.Contains(report.String(), "nmap-os-db             MISSING") {
+		t.Errorf("Missing data files were not reported:\n%s", report)
+	}
+}
+
+func TestScan_CheckEnvironment_runner(t *testing.T) {
+	var got []string
+	runner := funcRunner(func(args []string) (string, int) {
+		got = args
+		return testOfficialVersionOutput, 0
+	})
+
+	report := Init().SetRunner(runner).SetPrivileges(Privileges{Root: true}).CheckEnvironment()
+	if strings.Join(got, " ") != "--version" {
+		t.Fatalf("nmap --version was not run with the scan's runner: %v", got)
+	}
+	if report.NmapInfo == nil || report.NmapInfo.Version.String() != "7.94" {
+		t.Fatalf("Wrong nmap info: %+v", report.NmapInfo)
+	}
+	if !report.Privileges.Root {
+		t.Errorf("The scan's privileges were not reported")
+	}
+	for _, warning := range report.Warnings {
+		if strings.Contains(warning, "Lua") || strings.Contains(warning, "OpenSSL") || strings.Contains(warning, "privileged") {
+			t.Errorf("Unexpected warning for the official build: %s", warning)
+		}
+	}
+}
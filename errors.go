@@ -0,0 +1,159 @@
+package nmap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNmapNotFound is returned when the nmap binary can't be found
+var ErrNmapNotFound = errors.New("nmap was not found, install it from https://nmap.org")
+
+// ConfigError is a problem found while building a Scan. Method is the builder
+// function that was called and Arg is the argument that caused the problem.
+type ConfigError struct {
+	Method string
+	Arg    string
+	Err    error
+}
+
+// Error returns the builder call along with the problem
+func (c *ConfigError) Error() string {
+	return c.Method + "(" + strconv.Quote(c.Arg) + "): " + c.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (c *ConfigError) Unwrap() error {
+	return c.Err
+}
+
+// ConfigErrors is every problem found in a Scan's configuration. It is
+// returned by Validate and Run when the Scan cannot be run.
+type ConfigErrors []error
+
+// Error joins all of the errors, one per line
+func (c ConfigErrors) Error() string {
+	lines := make([]string, 0, len(c))
+	for _, err := range c {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the list of errors so errors.Is and errors.As can look at
+// each of them
+func (c ConfigErrors) Unwrap() []error {
+	return c
+}
+
+// Is returns true when one of the errors is target. It lets errors.Is look
+// at each error on Go versions without multi-error Unwrap.
+func (c ConfigErrors) Is(target error) bool {
+	for _, err := range c {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error that matches target. It lets errors.As look at
+// each error on Go versions without multi-error Unwrap.
+func (c ConfigErrors) As(target interface{}) bool {
+	for _, err := range c {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitError is returned when nmap exits with a non-zero exit code. Stderr is
+// the end of what nmap printed to stderr.
+type ExitError struct {
+	Code     int
+	Stderr   string
+	Warnings []Warning
+}
+
+// Error returns the exit code and stderr
+func (e *ExitError) Error() string {
+	if e.notFound() {
+		return fmt.Sprintf("%s: exit status %d\n%s", ErrNmapNotFound, e.Code, e.Stderr)
+	}
+	return fmt.Sprintf("exit status %d\n%s", e.Code, e.Stderr)
+}
+
+// Is returns true for ErrNmapNotFound when nmap couldn't be run, so the
+// ExitError is found by both errors.Is and errors.As
+func (e *ExitError) Is(target error) bool {
+	return target == ErrNmapNotFound && e.notFound()
+}
+
+// notFound returns true for exit code 127, which is the shell's exit code for
+// a missing command. That is what happens when nmap isn't installed where a
+// Runner's Prefix runs it.
+func (e *ExitError) notFound() bool {
+	return e.Code == 127
+}
+
+// PrivilegeError is returned when the scan uses features that need root or
+// raw socket capabilities which nmap won't have. It is returned by Validate
+// before nmap is run, and by Run when nmap quits because it isn't privileged.
+type PrivilegeError struct {
+	// Flags are the flags that need privileges. They are not known when the
+	// error comes from nmap.
+	Flags []string
+	// Err is the ExitError when the error comes from nmap
+	Err error
+}
+
+// Error returns the flags that need privileges
+func (p *PrivilegeError) Error() string {
+	const hint = "(run as root or give nmap the CAP_NET_RAW and CAP_NET_ADMIN capabilities)"
+	if len(p.Flags) == 0 {
+		return "nmap requires root privileges for this scan " + hint
+	}
+	return "Flags " + strings.Join(p.Flags, ", ") + " require root privileges " + hint
+}
+
+// Unwrap returns the ExitError from nmap
+func (p *PrivilegeError) Unwrap() error {
+	return p.Err
+}
+
+// InvalidArgumentError is returned by Run when nmap rejects one of its
+// arguments
+type InvalidArgumentError struct {
+	Option  string
+	Message string
+	// Err is the ExitError from nmap
+	Err error
+}
+
+// Error returns nmap's message about the argument
+func (i *InvalidArgumentError) Error() string {
+	return "nmap rejected the argument '" + i.Option + "': " + i.Message
+}
+
+// Unwrap returns the ExitError from nmap
+func (i *InvalidArgumentError) Unwrap() error {
+	return i.Err
+}
+
+// exitError converts a non-zero exit code into the most specific error
+func exitError(code int, stderr []byte, warnings []Warning) error {
+	exitErr := &ExitError{code, string(stderr), warnings}
+
+	if exitErr.notFound() {
+		return exitErr
+	}
+	if warning, ok := hasWarning(warnings, WarningUnknownOption); ok {
+		return &InvalidArgumentError{warning.Option, warning.Message, exitErr}
+	}
+	if _, ok := hasWarning(warnings, WarningRequiresRoot); ok {
+		return &PrivilegeError{Err: exitErr}
+	}
+	return exitErr
+}
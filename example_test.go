@@ -0,0 +1,130 @@
+package nmap_test
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/t94j0/nmap"
+	"github.com/t94j0/nmap/nmaptest"
+)
+
+// fakeNmap replays the fixture for every scan, so that the examples don't
+// need nmap, root or a network connection
+func fakeNmap(fixture string) *nmaptest.FakeRunner {
+	runner := nmaptest.NewFakeRunner()
+	response := nmaptest.MustFixture(fixture)
+	runner.Default = &response
+	runner.RunAs = nmap.Privileges{Root: true}
+	return runner
+}
+
+// stoppedWebServer replays the localhost fixture with port 80 closed
+func stoppedWebServer() *nmaptest.FakeRunner {
+	runner := fakeNmap("localhost-connect")
+	runner.Default.Stdout = bytes.Replace(runner.Default.Stdout,
+		[]byte(`portid="80"><state state="open"`), []byte(`portid="80"><state state="closed"`), 1)
+	return runner
+}
+
+func ExampleScan_GetHost() {
+	// All online hosts are added to the `scan` object
+	scan, _ := nmap.Init().
+		AddHosts("scanme.nmap.org").
+		SetRunner(fakeNmap("scanme-version")).
+		Run()
+	// GetHost allows you to select one host from the list
+	targetHost, _ := scan.GetHost("45.33.32.156")
+	fmt.Println(targetHost.Address)
+	// It also searches hostnames
+	targetHost, _ = scan.GetHost("scanme.nmap.org")
+	fmt.Println(targetHost.Address)
+	// Output:
+	// 45.33.32.156
+	// 45.33.32.156
+}
+
+func ExampleScan_Run() {
+	scan, _ := nmap.Init().
+		AddHosts("localhost").
+		AddPorts(22, 80, 443).
+		SetRunner(fakeNmap("localhost-connect")).
+		Run()
+	fmt.Print(scan.ToString())
+	// Output:
+	// 127.0.0.1 is up
+	// Hostnames:
+	//   localhost/user
+	//   localhost/PTR
+	// Ports:
+	//   Port 22/tcp (ssh) is open
+	//   Port 80/tcp (http) is open
+	//   Port 443/tcp (https) is closed
+}
+
+func ExampleScan_Intense() {
+	scan, _ := nmap.Init().
+		AddHosts("scanme.nmap.org").
+		Intense().
+		SetRunner(fakeNmap("scanme-version")).
+		Run()
+	host, _ := scan.GetHost("scanme.nmap.org")
+	for _, port := range host.Ports {
+		fmt.Println(port.ID, port.Service)
+	}
+	// Output:
+	// 22 ssh
+	// 80 http
+	// 9929 nping-echo
+	// 31337 tcpwrapped
+}
+
+func ExampleHost_Diff() {
+	// Scan localhost once
+	scan, _ := nmap.Init().
+		AddHosts("localhost").
+		SetRunner(fakeNmap("localhost-connect")).
+		Run()
+	firstHost, _ := scan.GetHost("localhost")
+
+	// Rescan localhost after the web server is stopped
+	scan, _ = scan.SetRunner(stoppedWebServer()).Run()
+	secondHost, _ := scan.GetHost("localhost")
+
+	// Get list of added and removed ports
+	added, removed := firstHost.Diff(secondHost)
+	for _, port := range added {
+		fmt.Println("+", port.ID, port.Service)
+	}
+	for _, port := range removed {
+		fmt.Println("-", port.ID, port.Service)
+	}
+	// Output:
+	// - 80 http
+}
+
+func ExampleScan_Rescan() {
+	// Scan localhost once
+	scan, err := nmap.Init().
+		AddHosts("localhost").
+		AddPorts(22, 80, 443).
+		SetRunner(fakeNmap("localhost-connect")).
+		Run()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Rescan the hosts that were up after the web server is stopped, and
+	// print what changed
+	_, diff, err := scan.SetRunner(stoppedWebServer()).Rescan(nmap.RescanOptions{UpOnly: true})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(diff.Text())
+	// Output:
+	// localhost (127.0.0.1):
+	//  PORT   STATE  SERVICE VERSION
+	// -80/tcp open   http
+	// +80/tcp closed http
+}
@@ -0,0 +1,354 @@
+package nmap
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Filter selects hosts and ports from a Scan. Host is checked against each
+// host and Port against each of the host's ports. A nil function matches
+// everything.
+type Filter struct {
+	Host func(Host) bool
+	Port func(Port) bool
+}
+
+// Filter returns a new Scan with only the hosts and ports that match the
+// filter. When the filter has a Port function, hosts without any matching
+// ports are left out.
+func (s Scan) Filter(filter Filter) Scan {
+	output := s
+	output.Hosts = make(map[string]Host)
+
+	for address, host := range s.Hosts {
+		if filter.Host != nil && !filter.Host(host) {
+			continue
+		}
+		if filter.Port != nil {
+			var ports []Port
+			for _, port := range host.Ports {
+				if filter.Port(port) {
+					ports = append(ports, port)
+				}
+			}
+			if len(ports) == 0 {
+				continue
+			}
+			host.Ports = ports
+		}
+		output.Hosts[address] = host
+	}
+	return output
+}
+
+// FilterHosts returns a new Scan with only the hosts that match
+func (s Scan) FilterHosts(match func(Host) bool) Scan {
+	return s.Filter(Filter{Host: match})
+}
+
+// FilterPorts returns a new Scan with only the ports that match. Hosts without
+// any matching ports are left out.
+func (s Scan) FilterPorts(match func(Port) bool) Scan {
+	return s.Filter(Filter{Port: match})
+}
+
+// Query filters the scan with a filter expression. See ParseFilter for the
+// syntax.
+//
+// E.x. `scan.Query("port:443 state:open product:nginx")` returns the hosts with
+// nginx on an open port 443, with only that port.
+func (s Scan) Query(expression string) (Scan, error) {
+	filter, err := ParseFilter(expression)
+	if err != nil {
+		return s, err
+	}
+	return s.Filter(filter), nil
+}
+
+// FilterError is returned by ParseFilter for a term that can't be parsed
+type FilterError struct {
+	Term   string
+	Reason string
+}
+
+// Error returns the term and the reason it was rejected
+func (f *FilterError) Error() string {
+	return "Filter term '" + f.Term + "' " + f.Reason
+}
+
+// filterTerm matches one field of a host or a port
+type filterTerm struct {
+	host func(Host, string) bool
+	port func(Port, string) bool
+}
+
+// filterTerms are the keys that can be used in filter expressions
+var filterTerms = map[string]filterTerm{
+	// Host terms
+	"host": {host: func(h Host, value string) bool {
+		if _, network, err := net.ParseCIDR(value); err == nil {
+			ip := net.ParseIP(h.Address)
+			return ip != nil && network.Contains(ip)
+		}
+		return h.Address == value
+	}},
+	"hostname": {host: func(h Host, value string) bool {
+		for _, hostname := range h.Hostnames {
+			if matchFold(hostname.Name, value) {
+				return true
+			}
+		}
+		return false
+	}},
+	"status": {host: func(h Host, value string) bool {
+		return strings.EqualFold(h.State, value)
+	}},
+	"os": {host: func(h Host, value string) bool {
+		for _, match := range h.OSMatches {
+			if containsFold(match.Name, value) {
+				return true
+			}
+			for _, class := range match.Classes {
+				if containsFold(class.Family, value) || containsFold(class.Vendor, value) {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+	"mac": {host: func(h Host, value string) bool {
+		return strings.EqualFold(h.MACAddress, value)
+	}},
+	"vendor": {host: func(h Host, value string) bool {
+		return containsFold(h.Vendor, value)
+	}},
+
+	// Port terms
+	"port": {port: func(p Port, value string) bool {
+		low, high, err := parsePortRange(value)
+		return err == nil && p.ID >= low && p.ID <= high
+	}},
+	"proto": {port: func(p Port, value string) bool {
+		return strings.EqualFold(p.Protocol, value)
+	}},
+	"state": {port: func(p Port, value string) bool {
+		return strings.EqualFold(p.State, value)
+	}},
+	"service": {port: func(p Port, value string) bool {
+		return strings.EqualFold(p.Service, value)
+	}},
+	"product": {port: func(p Port, value string) bool {
+		return containsFold(p.Product, value)
+	}},
+	"version": {port: func(p Port, value string) bool {
+		return containsFold(p.Version, value)
+	}},
+	"cpe": {port: func(p Port, value string) bool {
+		for _, cpe := range p.CPEs {
+			if strings.HasPrefix(strings.ToLower(cpe), strings.ToLower(value)) {
+				return true
+			}
+		}
+		return false
+	}},
+	"script": {port: func(p Port, value string) bool {
+		for _, script := range p.Scripts {
+			if strings.EqualFold(script.Name, value) {
+				return true
+			}
+		}
+		return false
+	}},
+	"output": {port: func(p Port, value string) bool {
+		for _, script := range p.Scripts {
+			if containsFold(script.Output, value) {
+				return true
+			}
+		}
+		return false
+	}},
+}
+
+// ParseFilter parses a filter expression into a Filter. An expression is a
+// list of `key:value` terms separated by spaces, and every term must match.
+// Values with spaces can be quoted, E.x. `product:"Apache httpd"`. A term
+// starting with `-` must not match, and a value can list several values
+// separated by commas, where any of them can match.
+//
+// Host terms select hosts:
+//
+//	host:10.0.0.1 host:10.0.0.0/24   address or CIDR range
+//	hostname:example.com            hostname, `*` matches any characters
+//	status:up                       host state
+//	os:linux                        OS match name, family or vendor
+//	mac:00:11:22:33:44:55 vendor:dell
+//
+// Port terms select ports. A host is kept when one of its ports matches all of
+// the port terms, and only the matching ports are kept:
+//
+//	port:443 port:1-1024            port number or range
+//	proto:tcp state:open service:http
+//	product:nginx version:1.18      version detection results
+//	cpe:cpe:/a:apache               CPE prefix
+//	script:http-title output:admin  script name or script output
+//
+// Text is matched without case, and product, version, os, vendor and output
+// only need to contain the value.
+func ParseFilter(expression string) (Filter, error) {
+	var hostMatchers []func(Host) bool
+	var portMatchers []func(Port) bool
+
+	terms, err := splitFilter(expression)
+	if err != nil {
+		return Filter{}, err
+	}
+	for _, term := range terms {
+		negate := strings.HasPrefix(term, "-")
+		i := strings.Index(term, ":")
+		if i == -1 {
+			return Filter{}, &FilterError{term, "must be in the form key:value"}
+		}
+		key := strings.ToLower(strings.TrimPrefix(term[:i], "-"))
+		values := strings.Split(unquote(term[i+1:]), ",")
+		for _, value := range values {
+			if value == "" {
+				return Filter{}, &FilterError{term, "is missing a value"}
+			}
+		}
+
+		matcher, ok := filterTerms[key]
+		if !ok {
+			return Filter{}, &FilterError{term, "uses an unknown key '" + key + "'"}
+		}
+		if key == "port" {
+			for _, value := range values {
+				if _, _, err := parsePortRange(value); err != nil {
+					return Filter{}, &FilterError{term, err.Error()}
+				}
+			}
+		}
+
+		if matcher.host != nil {
+			match := matcher.host
+			hostMatchers = append(hostMatchers, func(h Host) bool {
+				for _, value := range values {
+					if match(h, value) {
+						return !negate
+					}
+				}
+				return negate
+			})
+		} else {
+			match := matcher.port
+			portMatchers = append(portMatchers, func(p Port) bool {
+				for _, value := range values {
+					if match(p, value) {
+						return !negate
+					}
+				}
+				return negate
+			})
+		}
+	}
+
+	var filter Filter
+	if len(hostMatchers) != 0 {
+		filter.Host = func(h Host) bool {
+			for _, match := range hostMatchers {
+				if !match(h) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	if len(portMatchers) != 0 {
+		filter.Port = func(p Port) bool {
+			for _, match := range portMatchers {
+				if !match(p) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return filter, nil
+}
+
+// splitFilter splits an expression on spaces that aren't in quotes
+func splitFilter(expression string) (terms []string, err error) {
+	term := ""
+	quoted := false
+	for _, c := range expression {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			term += string(c)
+		case (c == ' ' || c == '\t' || c == '\n') && !quoted:
+			if term != "" {
+				terms = append(terms, term)
+			}
+			term = ""
+		default:
+			term += string(c)
+		}
+	}
+	if quoted {
+		return nil, &FilterError{term, "has an unclosed quote"}
+	}
+	if term != "" {
+		terms = append(terms, term)
+	}
+	return
+}
+
+// unquote removes the quotes around a value
+func unquote(value string) string {
+	return strings.Replace(value, `"`, "", -1)
+}
+
+// parsePortRange parses a port or a range of ports, E.x. `443` or `1-1024`
+func parsePortRange(value string) (low, high uint32, err error) {
+	lowText, highText := value, value
+	if i := strings.Index(value, "-"); i != -1 {
+		lowText, highText = value[:i], value[i+1:]
+	}
+	l, err := strconv.ParseUint(lowText, 10, 16)
+	if err != nil {
+		return 0, 0, errors.New("has an invalid port '" + lowText + "'")
+	}
+	h, err := strconv.ParseUint(highText, 10, 16)
+	if err != nil {
+		return 0, 0, errors.New("has an invalid port '" + highText + "'")
+	}
+	return uint32(l), uint32(h), nil
+}
+
+// containsFold returns true when s contains substr, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// matchFold matches a name against a pattern where `*` matches any
+// characters, ignoring case
+func matchFold(name, pattern string) bool {
+	name, pattern = strings.ToLower(name), strings.ToLower(pattern)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return name == pattern
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i == -1 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, parts[len(parts)-1])
+}
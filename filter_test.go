@@ -0,0 +1,84 @@
+package nmap
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func filterScan() Scan {
+	scan := Init()
+	scan.Hosts["10.0.0.1"] = Host{Address: "10.0.0.1", State: "up",
+		Hostnames: []Hostname{{"web.example.com", "PTR"}},
+		OSMatches: []OSMatch{{Name: "Linux 5.0 - 5.14", Classes: []OSClass{{Family: "Linux"}}}},
+		Ports: []Port{
+			{Protocol: "tcp", ID: 22, State: "open", Service: "ssh", Product: "OpenSSH"},
+			{Protocol: "tcp", ID: 443, State: "open", Service: "https", Product: "nginx",
+				CPEs: []string{"cpe:/a:igor_sysoev:nginx:1.18.0"}},
+		}}
+	scan.Hosts["10.0.0.2"] = Host{Address: "10.0.0.2", State: "up",
+		OSMatches: []OSMatch{{Name: "Microsoft Windows Server 2019", Classes: []OSClass{{Family: "Windows"}}}},
+		Ports: []Port{
+			{Protocol: "tcp", ID: 443, State: "open", Service: "https", Product: "Microsoft IIS httpd"},
+			{Protocol: "tcp", ID: 3389, State: "filtered", Service: "ms-wbt-server"},
+		}}
+	scan.Hosts["10.0.1.1"] = Host{Address: "10.0.1.1", State: "up",
+		Ports: []Port{{Protocol: "udp", ID: 53, State: "open", Service: "domain"}}}
+	return scan
+}
+
+// queryResult lists the matching hosts and ports, E.x. `10.0.0.1:443/tcp`
+func queryResult(scan Scan) string {
+	var results []string
+	for address, host := range scan.Hosts {
+		for _, port := range host.Ports {
+			results = append(results, address+":"+strconv.Itoa(int(port.ID))+"/"+port.Protocol)
+		}
+	}
+	sort.Strings(results)
+	return strings.Join(results, " ")
+}
+
+func TestScan_Query(t *testing.T) {
+	scan := filterScan()
+	tests := map[string]string{
+		"port:443 state:open product:nginx os:linux": "10.0.0.1:443/tcp",
+		"port:443":                          "10.0.0.1:443/tcp 10.0.0.2:443/tcp",
+		`product:"microsoft iis"`:           "10.0.0.2:443/tcp",
+		"host:10.0.0.0/24 -state:filtered":  "10.0.0.1:22/tcp 10.0.0.1:443/tcp 10.0.0.2:443/tcp",
+		"hostname:*.EXAMPLE.com port:1-100": "10.0.0.1:22/tcp",
+		"service:domain,ssh":                "10.0.0.1:22/tcp 10.0.1.1:53/udp",
+		"os:windows":                        "10.0.0.2:3389/tcp 10.0.0.2:443/tcp",
+		"cpe:cpe:/a:igor_sysoev:nginx":      "10.0.0.1:443/tcp",
+	}
+	for expression, expected := range tests {
+		output, err := scan.Query(expression)
+		if err != nil {
+			t.Errorf("%s: %v", expression, err)
+			continue
+		}
+		if got := queryResult(output); got != expected {
+			t.Errorf("%s: expected %q, got %q", expression, expected, got)
+		}
+	}
+
+	if len(scan.Hosts) != 3 || len(scan.Hosts["10.0.0.1"].Ports) != 2 {
+		t.Error("Query should not change the original scan")
+	}
+}
+
+func TestParseFilter_errors(t *testing.T) {
+	for _, expression := range []string{"443", "color:blue", "port:http", `product:"nginx`, "state:"} {
+		if _, err := ParseFilter(expression); err == nil {
+			t.Errorf("Expected an error for %q", expression)
+		}
+	}
+}
+
+func TestScan_FilterHosts(t *testing.T) {
+	output := filterScan().FilterHosts(func(h Host) bool { return h.OSFamily() == "Linux" })
+	if len(output.Hosts) != 1 || len(output.Hosts["10.0.0.1"].Ports) != 2 {
+		t.Errorf("Expected the Linux host with all of its ports, got %v", output.Hosts)
+	}
+}
@@ -0,0 +1,370 @@
+// Package history keeps scans on disk so that they can be listed, loaded and
+// compared later.
+//
+// A Store is a directory. Each scan is saved as JSON in the `scans` directory,
+// and `index.json` has the Record of every scan so that scans can be listed
+// without loading them. Files are replaced by renaming a temporary file, so a
+// crash never leaves a file half written. A Store is safe to use from several
+// goroutines, but only one process should write to a directory at a time.
+//
+//	store, err := history.Open("scans")
+//	record, err := store.Save(scan, "weekly")
+//	records, err := store.List(history.Query{Target: "10.0.0.0/24", Tag: "weekly"})
+//	events, err := store.PortHistory("10.0.0.1", "tcp", 22)
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/t94j0/nmap"
+)
+
+// ErrNotFound is returned when there is no scan with an ID
+var ErrNotFound = errors.New("Scan not found")
+
+const (
+	indexFile = "index.json"
+	scansDir  = "scans"
+)
+
+// Record describes a saved scan
+type Record struct {
+	ID string `json:"id"`
+	// Time is when the scan started, or when it was saved if the scan has no
+	// start time
+	Time  time.Time `json:"time"`
+	Saved time.Time `json:"saved"`
+	// Args are the arguments of the scan, from DisplayArgs
+	Args string   `json:"args"`
+	Tags []string `json:"tags"`
+	// Addresses and Hostnames are the hosts in the scan
+	Addresses []string `json:"addresses"`
+	Hostnames []string `json:"hostnames"`
+	HostsUp   int      `json:"hosts_up"`
+}
+
+// HasTag returns true when the scan was saved with a tag
+func (r Record) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTarget returns true when the scan has a host with an address or a
+// hostname, or a host in a CIDR range, E.x. `10.0.0.0/24`. Hostnames are
+// matched without case.
+func (r Record) HasTarget(target string) bool {
+	if _, network, err := net.ParseCIDR(target); err == nil {
+		for _, address := range r.Addresses {
+			if ip := net.ParseIP(address); ip != nil && network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, address := range r.Addresses {
+		if address == target {
+			return true
+		}
+	}
+	for _, hostname := range r.Hostnames {
+		if strings.EqualFold(hostname, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query selects records in List. Zero values match every record.
+type Query struct {
+	// Since and Until select scans that started in a time range. Until is
+	// exclusive.
+	Since time.Time
+	Until time.Time
+	// Target selects scans with a host, see Record.HasTarget
+	Target string
+	// Tag selects scans saved with a tag
+	Tag string
+}
+
+// match returns true when the record matches the query
+func (q Query) match(r Record) bool {
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.Time.Before(q.Until) {
+		return false
+	}
+	if q.Target != "" && !r.HasTarget(q.Target) {
+		return false
+	}
+	if q.Tag != "" && !r.HasTag(q.Tag) {
+		return false
+	}
+	return true
+}
+
+// Store saves scans in a directory
+type Store struct {
+	dir     string
+	mu      sync.Mutex
+	records []Record
+}
+
+// Open opens the store in a directory, creating the directory if it doesn't
+// exist
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, scansDir), 0700); err != nil {
+		return nil, err
+	}
+
+	store := &Store{dir: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, indexFile))
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.records); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Save saves a scan with tags and returns its Record
+func (s *Store) Save(scan nmap.Scan, tags ...string) (Record, error) {
+	id, err := newID()
+	if err != nil {
+		return Record{}, err
+	}
+	record := Record{
+		ID:        id,
+		Time:      scan.Stats.Start,
+		Saved:     time.Now().UTC(),
+		Args:      scan.DisplayArgs,
+		Tags:      append([]string{}, tags...),
+		Addresses: []string{},
+		Hostnames: []string{},
+	}
+	if record.Time.IsZero() {
+		record.Time = record.Saved
+	}
+	for address, host := range scan.Hosts {
+		record.Addresses = append(record.Addresses, address)
+		for _, hostname := range host.Hostnames {
+			record.Hostnames = append(record.Hostnames, hostname.Name)
+		}
+		if host.State == "up" {
+			record.HostsUp++
+		}
+	}
+	sort.Strings(record.Addresses)
+	sort.Strings(record.Hostnames)
+
+	data, err := json.Marshal(scan)
+	if err != nil {
+		return Record{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := writeFile(s.scanPath(id), data); err != nil {
+		return Record{}, err
+	}
+	records := append(append([]Record{}, s.records...), record)
+	if err := s.writeIndex(records); err != nil {
+		os.Remove(s.scanPath(id))
+		return Record{}, err
+	}
+	s.records = records
+	return record, nil
+}
+
+// Load loads a saved scan. Hosts of a loaded scan are rescanned on their own
+// ports by Host.Rescan, since the scan's options aren't saved.
+func (s *Store) Load(id string) (nmap.Scan, error) {
+	if _, ok := s.Record(id); !ok {
+		return nmap.Scan{}, ErrNotFound
+	}
+	data, err := ioutil.ReadFile(s.scanPath(id))
+	if err != nil {
+		return nmap.Scan{}, err
+	}
+	scan := nmap.Init()
+	if err := json.Unmarshal(data, &scan); err != nil {
+		return nmap.Scan{}, err
+	}
+	return scan, nil
+}
+
+// Record returns the record of a saved scan
+func (s *Store) Record(id string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.records {
+		if record.ID == id {
+			return record, true
+		}
+	}
+	return Record{}, false
+}
+
+// List returns the records that match the query, oldest first
+func (s *Store) List(query Query) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := []Record{}
+	for _, record := range s.records {
+		if query.match(record) {
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].Time.Equal(records[j].Time) {
+			return records[i].Time.Before(records[j].Time)
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
+
+// Delete removes a saved scan
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []Record
+	found := false
+	for _, record := range s.records {
+		if record.ID == id {
+			found = true
+			continue
+		}
+		records = append(records, record)
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if err := s.writeIndex(records); err != nil {
+		return err
+	}
+	s.records = records
+	return os.Remove(s.scanPath(id))
+}
+
+// PortEvent is a port of a host in one saved scan
+type PortEvent struct {
+	Record Record
+	// HostState is the state of the host in the scan
+	HostState string
+	// Port is nil when the scan didn't report the port, which usually means
+	// it was closed or wasn't scanned
+	Port *nmap.Port
+}
+
+// State returns the state of the port, or `missing` when the scan didn't
+// report it
+func (e PortEvent) State() string {
+	if e.Port == nil {
+		return "missing"
+	}
+	return e.Port.State
+}
+
+// PortHistory returns the port of a host in every saved scan that has the
+// host, oldest first. E.x. PortHistory("10.0.0.1", "tcp", 22).
+func (s *Store) PortHistory(address, protocol string, id uint32) ([]PortEvent, error) {
+	events := []PortEvent{}
+	for _, record := range s.List(Query{}) {
+		found := false
+		for _, a := range record.Addresses {
+			if a == address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		scan, err := s.Load(record.ID)
+		if err != nil {
+			return events, err
+		}
+		host := scan.Hosts[address]
+		event := PortEvent{Record: record, HostState: host.State}
+		for _, port := range host.Ports {
+			if port.ID == id && strings.EqualFold(port.Protocol, protocol) {
+				port := port
+				event.Port = &port
+				break
+			}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// scanPath returns the file a scan is saved in
+func (s *Store) scanPath(id string) string {
+	return filepath.Join(s.dir, scansDir, id+".json")
+}
+
+// writeIndex saves the records
+func (s *Store) writeIndex(records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.dir, indexFile), data)
+}
+
+// writeFile replaces a file by writing a temporary file and renaming it
+func writeFile(path string, data []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// newID returns an ID that sorts by the time it was made, E.x.
+// `20240102T150405Z-1a2b3c4d`
+func newID() (string, error) {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(random), nil
+}
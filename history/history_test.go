@@ -0,0 +1,124 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/t94j0/nmap"
+	"github.com/t94j0/nmap/nmaptest"
+)
+
+func testStore(t *testing.T) (*Store, string) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func testScan(start int64, hosts ...nmap.Host) nmap.Scan {
+	scan := nmaptest.NewScan(hosts...)
+	scan.DisplayArgs = "nmap -p22,80 10.0.0.0/24"
+	scan.Stats.Start = time.Unix(start, 0).UTC()
+	return scan
+}
+
+func TestStore_SaveLoad(t *testing.T) {
+	store, dir := testStore(t)
+	defer os.RemoveAll(dir)
+
+	scan := testScan(1700000000, nmaptest.NewHost("10.0.0.1").Hostname("web.example.com").
+		Ports(nmaptest.NewPort("tcp", 80).Service("http").Version("nginx", "1.18.0").Build()).Build())
+	record, err := store.Save(scan, "weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.Time.Equal(scan.Stats.Start) || record.HostsUp != 1 || !record.HasTag("weekly") {
+		t.Errorf("Unexpected record %+v", record)
+	}
+
+	// The scan is read back from disk by a new store
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := reopened.Load(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, ok := loaded.Hosts["10.0.0.1"]
+	if !ok || len(host.Ports) != 1 || host.Ports[0].Product != "nginx" || loaded.DisplayArgs != scan.DisplayArgs {
+		t.Errorf("Expected the saved scan, got %+v", loaded)
+	}
+
+	if _, err := reopened.Load("missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_List(t *testing.T) {
+	store, dir := testStore(t)
+	defer os.RemoveAll(dir)
+
+	late, _ := store.Save(testScan(1700000200, nmaptest.NewHost("10.0.1.1").Build()), "weekly")
+	early, _ := store.Save(testScan(1700000100, nmaptest.NewHost("10.0.0.1").Hostname("web.example.com").Build()))
+
+	records := store.List(Query{})
+	if len(records) != 2 || records[0].ID != early.ID || records[1].ID != late.ID {
+		t.Errorf("Expected records sorted by time, got %v", records)
+	}
+
+	tests := []struct {
+		query Query
+		want  string
+	}{
+		{Query{Tag: "weekly"}, late.ID},
+		{Query{Target: "10.0.0.0/24"}, early.ID},
+		{Query{Target: "WEB.example.com"}, early.ID},
+		{Query{Since: time.Unix(1700000150, 0)}, late.ID},
+		{Query{Until: time.Unix(1700000200, 0)}, early.ID},
+	}
+	for _, test := range tests {
+		records := store.List(test.query)
+		if len(records) != 1 || records[0].ID != test.want {
+			t.Errorf("List(%+v) = %v, expected %s", test.query, records, test.want)
+		}
+	}
+
+	if err := store.Delete(early.ID); err != nil {
+		t.Fatal(err)
+	}
+	if records := store.List(Query{}); len(records) != 1 {
+		t.Errorf("Expected the deleted scan to be gone, got %v", records)
+	}
+}
+
+func TestStore_PortHistory(t *testing.T) {
+	store, dir := testStore(t)
+	defer os.RemoveAll(dir)
+
+	store.Save(testScan(1700000100, nmaptest.NewHost("10.0.0.1").OpenTCP(22, 80).Build()))
+	store.Save(testScan(1700000200, nmaptest.NewHost("10.0.0.2").OpenTCP(22).Build()))
+	store.Save(testScan(1700000300, nmaptest.NewHost("10.0.0.1").OpenTCP(80).Build()))
+	store.Save(testScan(1700000400, nmaptest.NewHost("10.0.0.1").
+		Ports(nmaptest.NewPort("tcp", 22).State("filtered").Build()).Build()))
+
+	events, err := store.PortHistory("10.0.0.1", "tcp", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, event := range events {
+		states = append(states, event.State())
+	}
+	if len(states) != 3 || states[0] != "open" || states[1] != "missing" || states[2] != "filtered" {
+		t.Errorf("Unexpected port history %v", states)
+	}
+}
@@ -3,8 +3,6 @@ package nmap
 import (
 	"fmt"
 	"strings"
-
-	"github.com/t94j0/array"
 )
 
 // Host declares host information
@@ -16,6 +14,43 @@ type Host struct {
 	AddressType string
 	Hostnames   []Hostname
 	Ports       []Port
+	// MACAddress and Vendor are only found for hosts on the local network
+	MACAddress string
+	Vendor     string
+	// OSMatches are found by OS detection (`-O`), best match first
+	OSMatches []OSMatch
+}
+
+// OSMatch is an operating system that matches the host, E.x. `Linux 4.15 -
+// 5.8` with an accuracy of 96 percent
+type OSMatch struct {
+	Name     string
+	Accuracy int
+	Classes  []OSClass
+}
+
+// OSClass is the type of an OSMatch, E.x. a `general purpose` OS made by
+// `Linux` in the `Linux` family with generation `5.X`
+type OSClass struct {
+	Type       string
+	Vendor     string
+	Family     string
+	Generation string
+	Accuracy   int
+	CPEs       []string
+}
+
+// OSFamily returns the OS family of the best OS match, E.x. `Linux` or
+// `Windows`. It is empty when OS detection wasn't run or found nothing.
+func (h Host) OSFamily() string {
+	for _, match := range h.OSMatches {
+		for _, class := range match.Classes {
+			if class.Family != "" {
+				return class.Family
+			}
+		}
+	}
+	return ""
 }
 
 // Hostname declares the hostname and type
@@ -27,12 +62,20 @@ type Hostname struct {
 // cleanHost is used to conver from the rawHost format to a more usable format
 func (host rawHost) cleanHost() Host {
 	output := Host{
-		nil,
-		host.Status.State,
-		host.Address.Address,
-		host.Address.AddressType,
-		[]Hostname{},
-		[]Port{},
+		parentScan: nil,
+		State:      host.Status.State,
+		Hostnames:  []Hostname{},
+		Ports:      []Port{},
+	}
+
+	for _, address := range host.Addresses {
+		if address.AddressType == "mac" {
+			output.MACAddress = address.Address
+			output.Vendor = address.Vendor
+		} else if output.Address == "" {
+			output.Address = address.Address
+			output.AddressType = address.AddressType
+		}
 	}
 
 	for _, hostname := range host.Hostnames.Hostnames {
@@ -42,6 +85,20 @@ func (host rawHost) cleanHost() Host {
 	for _, port := range host.Ports.Ports {
 		output.Ports = append(output.Ports, port.cleanPort())
 	}
+	for _, match := range host.OS.Matches {
+		osMatch := OSMatch{Name: match.Name, Accuracy: match.Accuracy}
+		for _, class := range match.Classes {
+			osMatch.Classes = append(osMatch.Classes, OSClass{
+				Type:       class.Type,
+				Vendor:     class.Vendor,
+				Family:     class.Family,
+				Generation: class.Generation,
+				Accuracy:   class.Accuracy,
+				CPEs:       class.CPEs,
+			})
+		}
+		output.OSMatches = append(output.OSMatches, osMatch)
+	}
 
 	return output
 }
@@ -67,45 +124,59 @@ func (s Scan) GetHost(hostTarget string) (target Host, exists bool) {
 }
 
 // Rescan the target. Normally used for finding differences between scans
-// at two points in time.
+// at two points in time. The returned Scan has the options of the scan that
+// found the host, including its Runner and callbacks, but not Record. A host that wasn't found by a scan, such as one built by
+// hand, is scanned on its TCP and UDP ports, or on nmap's default ports when it
+// has none.
 func (h Host) Rescan() (scan Scan) {
-	return Init().
-		AddPorts(h.parentScan.configPorts...).
-		AddTCPPorts(h.parentScan.configTCPPorts...).
-		AddUDPPorts(h.parentScan.configUDPPorts...).
-		AddHosts(h.Address).
-		AddFlags(h.parentScan.configOpts...)
+	if h.parentScan != nil {
+		return h.parentScan.SetHosts(h.Address)
+	}
+
+	scan = Init().AddHosts(h.Address)
+	for _, port := range h.Ports {
+		switch port.Protocol {
+		case "tcp":
+			scan = scan.AddTCPPorts(uint16(port.ID))
+		case "udp":
+			scan = scan.AddUDPPorts(uint16(port.ID))
+		}
+	}
+	return scan
 }
 
 // Diff gets the difference between the the target host and the argument host.
-//The first returned value is the added ports and the second returned value is
-// the removed ports.
+// The first returned value is the added ports and the second returned value is
+// the removed ports. Ports are matched by protocol and port number, and closed
+// ports count as missing, so a port that goes from closed to open is added.
+// Use DiffScans to see every change to a port.
 func (h Host) Diff(altHost Host) (added []Port, removed []Port) {
-	targetPorts := h.Ports
-	altPorts := altHost.Ports
+	targetPorts := h.portMap()
+	altPorts := altHost.portMap()
 
-	addedWithClosed, ok := array.Except(altPorts, targetPorts).([]Port)
-	if !ok {
-		return
-	}
-	for _, add := range addedWithClosed {
-		if add.State != "closed" {
-			added = append(added, add)
+	for _, port := range altHost.Ports {
+		if old, ok := targetPorts[port.key()]; port.State != "closed" && (!ok || old.State == "closed") {
+			added = append(added, port)
 		}
 	}
-	removedWithClosed, ok := array.Except(targetPorts, altPorts).([]Port)
-	if !ok {
-		return
-	}
-	for _, remove := range removedWithClosed {
-		if remove.State != "closed" {
-			removed = append(removed, remove)
+	for _, port := range h.Ports {
+		if alt, ok := altPorts[port.key()]; port.State != "closed" && (!ok || alt.State == "closed") {
+			removed = append(removed, port)
 		}
 	}
 
 	return
 }
 
+// portMap returns the host's ports by protocol and port number
+func (h Host) portMap() map[PortKey]Port {
+	ports := make(map[PortKey]Port, len(h.Ports))
+	for _, port := range h.Ports {
+		ports[port.key()] = port
+	}
+	return ports
+}
+
 // ToString converts the host into a nicely formatted string
 func (h Host) ToString() (out string) {
 	out += fmt.Sprintf("%s is %s\n", h.Address, h.State)
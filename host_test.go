@@ -3,7 +3,6 @@ package nmap
 import (
 	"fmt"
 	"testing"
-	"time"
 )
 
 func portListToPortString(a []Port) (pl string) {
@@ -17,23 +16,6 @@ func portListToPortString(a []Port) (pl string) {
 	return
 }
 
-func ExampleHost_Diff() {
-	// Scan maxh.io one
-	scan, _ := Init().AddHosts("maxh.io").Run()
-	firstHost, _ := scan.GetHost("maxh.io")
-
-	// Wait 100 seconds
-	time.Sleep(100 * time.Second)
-
-	// Rescan maxh.io
-	scan, _ = scan.Run()
-	secondHost, _ := scan.GetHost("maxh.io")
-
-	// Get list of added and removed ports
-	added, removed := firstHost.Diff(secondHost)
-	fmt.Println(added, removed)
-}
-
 func TestHost_Diff_hostempty(t *testing.T) {
 	err := "Failed to find 8080 in additions"
 
@@ -161,3 +143,14 @@ func TestHost_Diff_state(t *testing.T) {
 		t.Errorf("additions: %v\nremovals: %v\n", additions, removals)
 	}
 }
+
+func TestHost_Rescan_noParent(t *testing.T) {
+	host := Host{Address: "10.0.0.1", Ports: []Port{{ID: 22, Protocol: "tcp"}, {ID: 53, Protocol: "udp"}}}
+	args, err := host.Rescan().SetPrivileges(Privileges{Root: true}).CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(args); got != "[-oX - -sS -sU -pU:53,T:22 10.0.0.1]" {
+		t.Errorf("Expected the host's ports to be rescanned, got %s", got)
+	}
+}
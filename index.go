@@ -0,0 +1,140 @@
+package nmap
+
+import "strings"
+
+// Index is a lookup table over the hosts of a Scan. It is built once with
+// Scan.Index, and every lookup returns hosts sorted by IP.
+type Index struct {
+	hosts      []Host
+	byAddress  map[string]int
+	byHostname map[string][]int
+	byPort     map[PortKey][]int
+	byService  map[string][]int
+	byProduct  map[string][]int
+	byCPE      map[string][]int
+}
+
+// Index builds an Index over the scan's hosts. Only open ports are indexed,
+// including `open|filtered` ports, so Port lookups return the hosts that
+// expose the port. Hostnames, services, products and CPEs are looked up
+// without case.
+func (s Scan) Index() *Index {
+	index := &Index{
+		byAddress:  make(map[string]int),
+		byHostname: make(map[string][]int),
+		byPort:     make(map[PortKey][]int),
+		byService:  make(map[string][]int),
+		byProduct:  make(map[string][]int),
+		byCPE:      make(map[string][]int),
+	}
+
+	for i, address := range sortedAddresses(s.Hosts) {
+		host := s.Hosts[address]
+		index.hosts = append(index.hosts, host)
+		index.byAddress[address] = i
+		if host.MACAddress != "" {
+			index.byAddress[strings.ToLower(host.MACAddress)] = i
+		}
+
+		for _, hostname := range host.Hostnames {
+			addIndex(index.byHostname, strings.ToLower(hostname.Name), i)
+		}
+		for _, match := range host.OSMatches {
+			for _, class := range match.Classes {
+				for _, cpe := range class.CPEs {
+					addIndex(index.byCPE, strings.ToLower(cpe), i)
+				}
+			}
+		}
+		for _, port := range host.Ports {
+			if !isOpen(port.State) {
+				continue
+			}
+			key := PortKey{strings.ToLower(port.Protocol), port.ID}
+			index.byPort[key] = appendIndex(index.byPort[key], i)
+			if port.Service != "" {
+				addIndex(index.byService, strings.ToLower(port.Service), i)
+			}
+			if port.Product != "" {
+				addIndex(index.byProduct, strings.ToLower(port.Product), i)
+			}
+			for _, cpe := range port.CPEs {
+				addIndex(index.byCPE, strings.ToLower(cpe), i)
+			}
+		}
+	}
+
+	return index
+}
+
+// addIndex adds a host to the list for a key
+func addIndex(index map[string][]int, key string, host int) {
+	index[key] = appendIndex(index[key], host)
+}
+
+// appendIndex adds a host to a list. Hosts are indexed in order, so a host
+// that is already in the list is the last one.
+func appendIndex(hosts []int, host int) []int {
+	if len(hosts) != 0 && hosts[len(hosts)-1] == host {
+		return hosts
+	}
+	return append(hosts, host)
+}
+
+// lookup returns the hosts in a list
+func (i *Index) lookup(hosts []int) []Host {
+	output := make([]Host, 0, len(hosts))
+	for _, host := range hosts {
+		output = append(output, i.hosts[host])
+	}
+	return output
+}
+
+// Hosts returns every host sorted by IP
+func (i *Index) Hosts() []Host {
+	return append([]Host{}, i.hosts...)
+}
+
+// Len returns the number of hosts
+func (i *Index) Len() int {
+	return len(i.hosts)
+}
+
+// Address returns the host with an IP or MAC address
+func (i *Index) Address(address string) (Host, bool) {
+	host, ok := i.byAddress[address]
+	if !ok {
+		host, ok = i.byAddress[strings.ToLower(address)]
+	}
+	if !ok {
+		return Host{}, false
+	}
+	return i.hosts[host], true
+}
+
+// Hostname returns the hosts with a hostname, ignoring case
+func (i *Index) Hostname(name string) []Host {
+	return i.lookup(i.byHostname[strings.ToLower(name)])
+}
+
+// Port returns the hosts with an open port, E.x. Port("tcp", 22)
+func (i *Index) Port(protocol string, id uint32) []Host {
+	return i.lookup(i.byPort[PortKey{strings.ToLower(protocol), id}])
+}
+
+// Service returns the hosts with an open port running a service, E.x. `ssh`
+func (i *Index) Service(name string) []Host {
+	return i.lookup(i.byService[strings.ToLower(name)])
+}
+
+// Product returns the hosts with an open port running a product found by
+// version detection, E.x. `OpenSSH`
+func (i *Index) Product(product string) []Host {
+	return i.lookup(i.byProduct[strings.ToLower(product)])
+}
+
+// CPE returns the hosts with a CPE from version or OS detection, E.x.
+// `cpe:/a:openbsd:openssh:6.6.1p1`
+func (i *Index) CPE(cpe string) []Host {
+	return i.lookup(i.byCPE[strings.ToLower(cpe)])
+}
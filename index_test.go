@@ -0,0 +1,52 @@
+package nmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func addresses(hosts []Host) string {
+	var list []string
+	for _, host := range hosts {
+		list = append(list, host.Address)
+	}
+	return strings.Join(list, " ")
+}
+
+func TestScan_Index(t *testing.T) {
+	scan := filterScan()
+	scan.Hosts["10.0.0.10"] = Host{Address: "10.0.0.10", State: "up",
+		Hostnames: []Hostname{{"WEB.example.com", "user"}},
+		Ports: []Port{
+			{Protocol: "tcp", ID: 22, State: "open", Service: "ssh", Product: "OpenSSH"},
+			{Protocol: "tcp", ID: 2222, State: "open", Service: "ssh", Product: "OpenSSH"},
+		}}
+	scan.Hosts["::1"] = Host{Address: "::1", State: "up"}
+	index := scan.Index()
+
+	if got := addresses(index.Hosts()); got != "10.0.0.1 10.0.0.2 10.0.0.10 10.0.1.1 ::1" {
+		t.Errorf("Hosts are not sorted by IP: %s", got)
+	}
+	if host, ok := index.Address("10.0.0.2"); !ok || host.Address != "10.0.0.2" {
+		t.Error("Host was not found by address")
+	}
+
+	tests := []struct {
+		name     string
+		hosts    []Host
+		expected string
+	}{
+		{"hostname", index.Hostname("web.EXAMPLE.com"), "10.0.0.1 10.0.0.10"},
+		{"port", index.Port("TCP", 22), "10.0.0.1 10.0.0.10"},
+		{"filtered port", index.Port("tcp", 3389), ""},
+		{"service", index.Service("ssh"), "10.0.0.1 10.0.0.10"},
+		{"product", index.Product("openssh"), "10.0.0.1 10.0.0.10"},
+		{"cpe", index.CPE("cpe:/a:igor_sysoev:nginx:1.18.0"), "10.0.0.1"},
+		{"udp port", index.Port("udp", 53), "10.0.1.1"},
+	}
+	for _, test := range tests {
+		if got := addresses(test.hosts); got != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package nmap
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergePolicy decides which scan's port wins when scans disagree about a port
+type MergePolicy int
+
+const (
+	// NewestWins keeps the port from the scan that started last
+	NewestWins MergePolicy = iota
+	// MostOpenWins keeps the port with the most open state, E.x. an open port
+	// wins over a filtered one. Ports with the same state use NewestWins.
+	MostOpenWins
+)
+
+// stateRank orders port states from most to least open
+var stateRank = map[string]int{
+	"open":            6,
+	"open|filtered":   5,
+	"unfiltered":      4,
+	"filtered":        3,
+	"closed|filtered": 2,
+	"closed":          1,
+}
+
+// Observation is one scan seeing a port. Merged ports keep an Observation for
+// each scan the port was in.
+type Observation struct {
+	// Args are the arguments of the scan, from DisplayArgs
+	Args string
+	// Time is when the scan started
+	Time  time.Time
+	State string
+}
+
+// MergeScans merges scans with NewestWins. See MergePolicy.Merge.
+func MergeScans(scans ...Scan) Scan {
+	return NewestWins.Merge(scans...)
+}
+
+// Merge combines scans into one Scan. Hosts are matched by address and ports
+// by protocol and port number. When scans disagree about a port, the policy
+// picks the port to keep, and version information the winner is missing is
+// taken from the other scans of the same service. Hostnames and script output
+// from every scan are kept. Each port's Observations list every scan that saw
+// it, oldest first.
+//
+// Scans are ordered by Stats.Start. Scans without a start time keep their
+// place after the scan they were given after.
+func (policy MergePolicy) Merge(scans ...Scan) Scan {
+	// A scan without a start time is ordered as if it started with the scan
+	// given before it
+	type timedScan struct {
+		scan  Scan
+		start time.Time
+	}
+	timed := make([]timedScan, 0, len(scans))
+	var last time.Time
+	for _, scan := range scans {
+		if !scan.Stats.Start.IsZero() {
+			last = scan.Stats.Start
+		}
+		timed = append(timed, timedScan{scan, last})
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].start.Before(timed[j].start)
+	})
+	ordered := make([]Scan, 0, len(timed))
+	for _, t := range timed {
+		ordered = append(ordered, t.scan)
+	}
+
+	output := Init()
+	var args []string
+	for _, scan := range ordered {
+		if scan.NmapVersion != "" {
+			output.NmapVersion = scan.NmapVersion
+		}
+		if scan.DisplayArgs != "" {
+			args = append(args, scan.DisplayArgs)
+		}
+		output.Warnings = append(output.Warnings, scan.Warnings...)
+		if output.Stats.Start.IsZero() || (!scan.Stats.Start.IsZero() && scan.Stats.Start.Before(output.Stats.Start)) {
+			output.Stats.Start = scan.Stats.Start
+		}
+		if scan.Stats.End.After(output.Stats.End) {
+			output.Stats.End = scan.Stats.End
+		}
+
+		for _, address := range sortedAddresses(scan.Hosts) {
+			host := scan.Hosts[address]
+			if merged, ok := output.Hosts[address]; ok {
+				output.Hosts[address] = policy.mergeHost(merged, host, scan)
+			} else {
+				output.Hosts[address] = policy.mergeHost(Host{Address: address}, host, scan)
+			}
+		}
+	}
+	output.DisplayArgs = strings.Join(args, "; ")
+
+	for address, host := range output.Hosts {
+		host.parentScan = nil
+		output.Hosts[address] = host
+		output.Stats.HostsTotal++
+		if host.State == "up" {
+			output.Stats.HostsUp++
+		} else {
+			output.Stats.HostsDown++
+		}
+	}
+	if !output.Stats.Start.IsZero() && output.Stats.End.After(output.Stats.Start) {
+		output.Stats.Elapsed = output.Stats.End.Sub(output.Stats.Start)
+	}
+	return output
+}
+
+// mergeHost merges a host from a newer scan into the merged host
+func (policy MergePolicy) mergeHost(merged, host Host, scan Scan) Host {
+	if merged.State == "" || policy == NewestWins || host.State == "up" {
+		merged.State = host.State
+	}
+	if host.AddressType != "" {
+		merged.AddressType = host.AddressType
+	}
+	if host.MACAddress != "" {
+		merged.MACAddress = host.MACAddress
+		merged.Vendor = host.Vendor
+	}
+	if len(host.OSMatches) != 0 {
+		merged.OSMatches = host.OSMatches
+	}
+
+	for _, hostname := range host.Hostnames {
+		found := false
+		for _, existing := range merged.Hostnames {
+			if strings.EqualFold(existing.Name, hostname.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Hostnames = append(merged.Hostnames, hostname)
+		}
+	}
+
+	ports := make([]Port, len(merged.Ports))
+	copy(ports, merged.Ports)
+	positions := make(map[PortKey]int, len(ports))
+	for i, port := range ports {
+		positions[port.key()] = i
+	}
+	for _, port := range host.Ports {
+		// Ports from scans that were already merged keep their observations
+		if len(port.Observations) == 0 {
+			port.Observations = []Observation{{scan.DisplayArgs, scan.Stats.Start, port.State}}
+		}
+		if i, ok := positions[port.key()]; ok {
+			ports[i] = policy.mergePort(ports[i], port)
+		} else {
+			positions[port.key()] = len(ports)
+			ports = append(ports, port)
+		}
+	}
+	sort.SliceStable(ports, func(i, j int) bool { return portKeyLess(ports[i].key(), ports[j].key()) })
+	merged.Ports = ports
+
+	return merged
+}
+
+// mergePort merges a port from a newer scan into the merged port
+func (policy MergePolicy) mergePort(merged, port Port) Port {
+	winner, loser := port, merged
+	if policy == MostOpenWins && stateRank[merged.State] > stateRank[port.State] {
+		winner, loser = merged, port
+	}
+
+	if winner.Service == loser.Service {
+		if winner.Product == "" {
+			winner.Product = loser.Product
+		}
+		if winner.Version == "" {
+			winner.Version = loser.Version
+		}
+		if winner.ExtraInfo == "" {
+			winner.ExtraInfo = loser.ExtraInfo
+		}
+		if len(winner.CPEs) == 0 {
+			winner.CPEs = loser.CPEs
+		}
+	}
+
+	// Scripts from both ports are kept, with the winner's output for scripts
+	// that both of them ran
+	scripts := append([]Script{}, winner.Scripts...)
+	for _, script := range loser.Scripts {
+		found := false
+		for _, existing := range winner.Scripts {
+			if existing.Name == script.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			scripts = append(scripts, script)
+		}
+	}
+	winner.Scripts = scripts
+
+	winner.Observations = append(append([]Observation{}, merged.Observations...), port.Observations...)
+	return winner
+}
@@ -0,0 +1,75 @@
+package nmap
+
+import (
+	"testing"
+	"time"
+)
+
+func mergeScans() (tcp, udp, later Scan) {
+	tcp = Init()
+	tcp.DisplayArgs = "nmap -sS 10.0.0.1"
+	tcp.Stats.Start = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tcp.Hosts["10.0.0.1"] = Host{Address: "10.0.0.1", State: "up",
+		Hostnames: []Hostname{{"a.example.com", "PTR"}},
+		Ports: []Port{
+			{Protocol: "tcp", ID: 22, State: "open", Service: "ssh", Product: "OpenSSH", Version: "8.9p1"},
+			{Protocol: "tcp", ID: 80, State: "open", Service: "http",
+				Scripts: []Script{{Name: "http-title", Output: "Home"}}},
+		}}
+
+	udp = Init()
+	udp.DisplayArgs = "nmap -sU 10.0.0.1 10.0.0.2"
+	udp.Stats.Start = time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	udp.Hosts["10.0.0.1"] = Host{Address: "10.0.0.1", State: "up",
+		Hostnames: []Hostname{{"b.example.com", "user"}},
+		Ports:     []Port{{Protocol: "udp", ID: 53, State: "open", Service: "domain"}}}
+	udp.Hosts["10.0.0.2"] = Host{Address: "10.0.0.2", State: "up"}
+
+	later = Init()
+	later.DisplayArgs = "nmap -sS -sC 10.0.0.1"
+	later.Stats.Start = time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	later.Hosts["10.0.0.1"] = Host{Address: "10.0.0.1", State: "up",
+		Ports: []Port{
+			{Protocol: "tcp", ID: 22, State: "filtered", Service: "ssh"},
+			{Protocol: "tcp", ID: 80, State: "open", Service: "http",
+				Scripts: []Script{{Name: "http-server-header", Output: "nginx"}}},
+		}}
+	return
+}
+
+func TestMergeScans(t *testing.T) {
+	tcp, udp, later := mergeScans()
+	merged := MergeScans(later, udp, tcp)
+
+	if len(merged.Hosts) != 2 || merged.Stats.HostsUp != 2 {
+		t.Fatalf("Expected 2 hosts, got %v", merged.Hosts)
+	}
+	host := merged.Hosts["10.0.0.1"]
+	if len(host.Hostnames) != 2 || len(host.Ports) != 3 {
+		t.Fatalf("Hostnames and ports were not combined: %+v", host)
+	}
+
+	ssh := host.Ports[0]
+	if ssh.State != "filtered" || ssh.Product != "OpenSSH" {
+		t.Errorf("Expected the newest state with the older version information, got %+v", ssh)
+	}
+	if len(ssh.Observations) != 2 || ssh.Observations[0].State != "open" ||
+		ssh.Observations[1].Args != "nmap -sS -sC 10.0.0.1" || !ssh.Observations[1].Time.Equal(later.Stats.Start) {
+		t.Errorf("Observations were not kept: %+v", ssh.Observations)
+	}
+	if http := host.Ports[1]; len(http.Scripts) != 2 {
+		t.Errorf("Scripts were not combined: %+v", http.Scripts)
+	}
+	if dns := host.Ports[2]; dns.Protocol != "udp" || dns.ID != 53 {
+		t.Errorf("Expected the UDP port last, got %+v", dns)
+	}
+}
+
+func TestMergePolicy_Merge_mostopen(t *testing.T) {
+	tcp, udp, later := mergeScans()
+	merged := MostOpenWins.Merge(tcp, udp, later)
+
+	if ssh := merged.Hosts["10.0.0.1"].Ports[0]; ssh.State != "open" || ssh.Version != "8.9p1" {
+		t.Errorf("Expected the open port to win, got %+v", ssh)
+	}
+}
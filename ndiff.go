@@ -0,0 +1,474 @@
+package nmap
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Text formats the diff the way `ndiff` prints it. Changed hosts are listed
+// with a table of the ports that changed. Lines starting with `-` are from
+// the old scan, and lines starting with `+` are from the new scan.
+func (d ScanDiff) Text() string {
+	var out strings.Builder
+
+	oldBanner, newBanner := scanBanner(d.Old), scanBanner(d.New)
+	if oldBanner != newBanner {
+		out.WriteString("-" + oldBanner + "\n")
+		out.WriteString("+" + newBanner + "\n")
+		out.WriteString("\n")
+	}
+
+	for _, host := range d.hosts() {
+		host.writeText(&out)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// scanBanner is the line nmap starts its output with, E.x. `Nmap 7.94 scan
+// initiated Mon Mar  4 09:15:40 2024 as: nmap -oX - localhost`
+func scanBanner(s Scan) string {
+	banner := "Nmap " + s.NmapVersion + " scan"
+	if !s.Stats.Start.IsZero() {
+		banner += " initiated " + s.Stats.Start.Format(time.ANSIC)
+	}
+	if s.DisplayArgs != "" {
+		banner += " as: " + s.DisplayArgs
+	}
+	return banner
+}
+
+// hostChange is a host that is in the diff, in the order ndiff prints them.
+// old or new is nil when the host was only in one scan.
+type hostChange struct {
+	old   *Host
+	new   *Host
+	ports []PortDiff
+}
+
+// hosts returns the added, removed and changed hosts sorted by address
+func (d ScanDiff) hosts() []hostChange {
+	byAddress := make(map[string]Host)
+	changes := make(map[string]hostChange)
+	for i := range d.Added {
+		host := d.Added[i]
+		byAddress[host.Address] = host
+		changes[host.Address] = hostChange{new: &host, ports: addedPorts(host, true)}
+	}
+	for i := range d.Removed {
+		host := d.Removed[i]
+		byAddress[host.Address] = host
+		changes[host.Address] = hostChange{old: &host, ports: addedPorts(host, false)}
+	}
+	for _, hostDiff := range d.Changed {
+		old, new := hostDiff.Old, hostDiff.New
+		byAddress[hostDiff.Address] = new
+		changes[hostDiff.Address] = hostChange{&old, &new, hostDiff.Ports}
+	}
+
+	var hosts []hostChange
+	for _, address := range sortedAddresses(byAddress) {
+		hosts = append(hosts, changes[address])
+	}
+	return hosts
+}
+
+// addedPorts returns the diff of every port of a host that was only in one
+// scan
+func addedPorts(host Host, added bool) (ports []PortDiff) {
+	for i := range host.Ports {
+		port := host.Ports[i]
+		portDiff := PortDiff{Protocol: port.Protocol, ID: port.ID}
+		if added {
+			portDiff.New = &port
+			portDiff.Changes = diffPorts(Port{}, port)
+		} else {
+			portDiff.Old = &port
+			portDiff.Changes = diffPorts(port, Port{})
+		}
+		ports = append(ports, portDiff)
+	}
+	return
+}
+
+// writeText writes the host the way ndiff prints it
+func (h hostChange) writeText(out *strings.Builder) {
+	switch {
+	case h.old == nil:
+		out.WriteString("+" + hostTextName(*h.new) + ":\n")
+	case h.new == nil:
+		out.WriteString("-" + hostTextName(*h.old) + ":\n")
+	case hostTextName(*h.old) != hostTextName(*h.new):
+		out.WriteString("-" + hostTextName(*h.old) + ":\n")
+		out.WriteString("+" + hostTextName(*h.new) + ":\n")
+	default:
+		out.WriteString(" " + hostTextName(*h.new) + ":\n")
+	}
+
+	oldState, newState := "", ""
+	if h.old != nil {
+		oldState = h.old.State
+	}
+	if h.new != nil {
+		newState = h.new.State
+	}
+	if oldState != newState {
+		if oldState != "" {
+			out.WriteString("-Host is " + oldState + ".\n")
+		}
+		if newState != "" {
+			out.WriteString("+Host is " + newState + ".\n")
+		}
+	}
+
+	if len(h.ports) == 0 {
+		return
+	}
+
+	// Rows are marked with -, + or a space, and the columns are lined up
+	// after the marker like nmap's port table
+	type row struct {
+		marker  string
+		columns []string
+		scripts []string
+	}
+	rows := []row{{" ", []string{"PORT", "STATE", "SERVICE", "VERSION"}, nil}}
+	for _, port := range h.ports {
+		if port.Old != nil && port.New != nil && !portRowChanged(port.Changes) {
+			rows = append(rows, row{" ", portColumns(*port.New), nil})
+		} else {
+			if port.Old != nil {
+				rows = append(rows, row{"-", portColumns(*port.Old), nil})
+			}
+			if port.New != nil {
+				rows = append(rows, row{"+", portColumns(*port.New), nil})
+			}
+		}
+
+		// Script output is printed under the port when it changed
+		for _, change := range port.Changes {
+			if !strings.HasPrefix(change.Field, FieldScript) {
+				continue
+			}
+			name := strings.TrimPrefix(change.Field, FieldScript)
+			last := &rows[len(rows)-1]
+			if change.Old != "" {
+				for _, line := range scriptLines(name, change.Old) {
+					last.scripts = append(last.scripts, "-"+line)
+				}
+			}
+			if change.New != "" {
+				for _, line := range scriptLines(name, change.New) {
+					last.scripts = append(last.scripts, "+"+line)
+				}
+			}
+		}
+	}
+
+	widths := make([]int, 4)
+	for _, r := range rows {
+		for i, column := range r.columns {
+			if len(column) > widths[i] {
+				widths[i] = len(column)
+			}
+		}
+	}
+	for _, r := range rows {
+		line := r.marker
+		for i, column := range r.columns {
+			if i == len(r.columns)-1 {
+				line += column
+			} else {
+				line += column + strings.Repeat(" ", widths[i]-len(column)+1)
+			}
+		}
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+		for _, script := range r.scripts {
+			out.WriteString(script + "\n")
+		}
+	}
+}
+
+// portRowChanged returns true when a change shows up in the port table, and
+// not just in the script output
+func portRowChanged(changes []Change) bool {
+	for _, change := range changes {
+		if !strings.HasPrefix(change.Field, FieldScript) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostTextName is the host's name as ndiff prints it, E.x. `scanme.nmap.org
+// (45.33.32.156)`
+func hostTextName(host Host) string {
+	if len(host.Hostnames) != 0 {
+		return host.Hostnames[0].Name + " (" + host.Address + ")"
+	}
+	return host.Address
+}
+
+// portColumns returns the port table columns for a port
+func portColumns(port Port) []string {
+	return []string{
+		strconv.Itoa(int(port.ID)) + "/" + port.Protocol,
+		port.State,
+		port.Service,
+		portVersion(port),
+	}
+}
+
+// portVersion joins the product, version and extra info the way nmap does,
+// E.x. `OpenSSH 6.6.1p1 Ubuntu 2ubuntu2.13 (Ubuntu Linux; protocol 2.0)`
+func portVersion(port Port) string {
+	var parts []string
+	if port.Product != "" {
+		parts = append(parts, port.Product)
+	}
+	if port.Version != "" {
+		parts = append(parts, port.Version)
+	}
+	if port.ExtraInfo != "" {
+		parts = append(parts, "("+port.ExtraInfo+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// scriptLines formats script output the way nmap prints it under a port
+func scriptLines(name, output string) []string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) == 1 {
+		return []string{"|_" + name + ": " + lines[0]}
+	}
+
+	formatted := []string{strings.TrimRight("| "+name+": "+lines[0], " ")}
+	for i, line := range lines[1:] {
+		prefix := "|   "
+		if i == len(lines)-2 {
+			prefix = "|_  "
+		}
+		formatted = append(formatted, prefix+strings.TrimLeft(line, " "))
+	}
+	return formatted
+}
+
+// ndiffXML is the root of ndiff's XML output
+type ndiffXML struct {
+	XMLName  xml.Name      `xml:"nmapdiff"`
+	Version  string        `xml:"version,attr"`
+	ScanDiff ndiffScanDiff `xml:"scandiff"`
+}
+
+type ndiffScanDiff struct {
+	A         *ndiffScanSide  `xml:"a,omitempty"`
+	B         *ndiffScanSide  `xml:"b,omitempty"`
+	HostDiffs []ndiffHostDiff `xml:"hostdiff"`
+}
+
+// ndiffScanSide holds the parts of a scan that are different in each scan
+type ndiffScanSide struct {
+	NmapRun ndiffNmapRun `xml:"nmaprun"`
+}
+
+type ndiffNmapRun struct {
+	Scanner  string `xml:"scanner,attr"`
+	Args     string `xml:"args,attr,omitempty"`
+	Start    string `xml:"start,attr,omitempty"`
+	StartStr string `xml:"startstr,attr,omitempty"`
+	Version  string `xml:"version,attr,omitempty"`
+}
+
+// ndiffHostDiff wraps a host in <a> or <b> when it is only in one scan
+type ndiffHostDiff struct {
+	A    *ndiffHostSide `xml:"a,omitempty"`
+	B    *ndiffHostSide `xml:"b,omitempty"`
+	Host *ndiffHost     `xml:"host,omitempty"`
+}
+
+type ndiffHostSide struct {
+	Host ndiffHost `xml:"host"`
+}
+
+type ndiffHost struct {
+	StateA    *ndiffStatusSide `xml:"a,omitempty"`
+	StateB    *ndiffStatusSide `xml:"b,omitempty"`
+	Status    *ndiffStatus     `xml:"status,omitempty"`
+	Addresses []ndiffAddress   `xml:"address"`
+	Hostnames *ndiffHostnames  `xml:"hostnames,omitempty"`
+	Ports     *ndiffPorts      `xml:"ports,omitempty"`
+}
+
+type ndiffStatusSide struct {
+	Status ndiffStatus `xml:"status"`
+}
+
+type ndiffStatus struct {
+	State string `xml:"state,attr"`
+}
+
+type ndiffAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+	Vendor   string `xml:"vendor,attr,omitempty"`
+}
+
+type ndiffHostnames struct {
+	Hostnames []ndiffHostname `xml:"hostname"`
+}
+
+type ndiffHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type ndiffPorts struct {
+	Ports     []ndiffPort     `xml:"port"`
+	PortDiffs []ndiffPortDiff `xml:"portdiff"`
+}
+
+type ndiffPortDiff struct {
+	A *ndiffPortSide `xml:"a,omitempty"`
+	B *ndiffPortSide `xml:"b,omitempty"`
+}
+
+type ndiffPortSide struct {
+	Port ndiffPort `xml:"port"`
+}
+
+type ndiffPort struct {
+	Protocol string        `xml:"protocol,attr"`
+	PortID   uint32        `xml:"portid,attr"`
+	State    ndiffStatus   `xml:"state"`
+	Service  *ndiffService `xml:"service,omitempty"`
+	Scripts  []ndiffScript `xml:"script"`
+}
+
+type ndiffService struct {
+	Name      string   `xml:"name,attr"`
+	Product   string   `xml:"product,attr,omitempty"`
+	Version   string   `xml:"version,attr,omitempty"`
+	ExtraInfo string   `xml:"extrainfo,attr,omitempty"`
+	Method    string   `xml:"method,attr,omitempty"`
+	CPEs      []string `xml:"cpe"`
+}
+
+type ndiffScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+// XML formats the diff the way `ndiff --xml` prints it. Hosts and ports that
+// are only in one scan are wrapped in <a> for the old scan or <b> for the new
+// scan, and a changed port is a <portdiff> holding both versions of it.
+func (d ScanDiff) XML() ([]byte, error) {
+	root := ndiffXML{Version: "1"}
+	if scanBanner(d.Old) != scanBanner(d.New) {
+		root.ScanDiff.A = &ndiffScanSide{ndiffRun(d.Old)}
+		root.ScanDiff.B = &ndiffScanSide{ndiffRun(d.New)}
+	}
+
+	for _, change := range d.hosts() {
+		switch {
+		case change.old == nil:
+			root.ScanDiff.HostDiffs = append(root.ScanDiff.HostDiffs,
+				ndiffHostDiff{B: &ndiffHostSide{ndiffFullHost(*change.new)}})
+		case change.new == nil:
+			root.ScanDiff.HostDiffs = append(root.ScanDiff.HostDiffs,
+				ndiffHostDiff{A: &ndiffHostSide{ndiffFullHost(*change.old)}})
+		default:
+			host := ndiffHostBase(*change.new)
+			if change.old.State != change.new.State {
+				host.StateA = &ndiffStatusSide{ndiffStatus{change.old.State}}
+				host.StateB = &ndiffStatusSide{ndiffStatus{change.new.State}}
+			} else {
+				host.Status = &ndiffStatus{change.new.State}
+			}
+			if len(change.ports) != 0 {
+				host.Ports = &ndiffPorts{}
+				for _, port := range change.ports {
+					portDiff := ndiffPortDiff{}
+					if port.Old != nil {
+						portDiff.A = &ndiffPortSide{ndiffFullPort(*port.Old)}
+					}
+					if port.New != nil {
+						portDiff.B = &ndiffPortSide{ndiffFullPort(*port.New)}
+					}
+					host.Ports.PortDiffs = append(host.Ports.PortDiffs, portDiff)
+				}
+			}
+			root.ScanDiff.HostDiffs = append(root.ScanDiff.HostDiffs, ndiffHostDiff{Host: &host})
+		}
+	}
+
+	output, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(output, '\n')...), nil
+}
+
+// ndiffRun returns the scan information for the <nmaprun> element
+func ndiffRun(s Scan) ndiffNmapRun {
+	run := ndiffNmapRun{Scanner: "nmap", Args: s.DisplayArgs, Version: s.NmapVersion}
+	if !s.Stats.Start.IsZero() {
+		run.Start = strconv.FormatInt(s.Stats.Start.Unix(), 10)
+		run.StartStr = s.Stats.Start.Format(time.ANSIC)
+	}
+	return run
+}
+
+// ndiffHostBase returns the addresses and hostnames of a host
+func ndiffHostBase(host Host) ndiffHost {
+	output := ndiffHost{
+		Addresses: []ndiffAddress{{host.Address, host.AddressType, ""}},
+	}
+	if host.MACAddress != "" {
+		output.Addresses = append(output.Addresses, ndiffAddress{host.MACAddress, "mac", host.Vendor})
+	}
+	if len(host.Hostnames) != 0 {
+		output.Hostnames = &ndiffHostnames{}
+		for _, hostname := range host.Hostnames {
+			output.Hostnames.Hostnames = append(output.Hostnames.Hostnames, ndiffHostname{hostname.Name, hostname.Type})
+		}
+	}
+	return output
+}
+
+// ndiffFullHost returns a host with its state and all of its ports
+func ndiffFullHost(host Host) ndiffHost {
+	output := ndiffHostBase(host)
+	output.Status = &ndiffStatus{host.State}
+	if len(host.Ports) != 0 {
+		output.Ports = &ndiffPorts{}
+		for _, port := range host.Ports {
+			output.Ports.Ports = append(output.Ports.Ports, ndiffFullPort(port))
+		}
+	}
+	return output
+}
+
+// ndiffFullPort returns a port with its service and scripts
+func ndiffFullPort(port Port) ndiffPort {
+	output := ndiffPort{
+		Protocol: port.Protocol,
+		PortID:   port.ID,
+		State:    ndiffStatus{port.State},
+	}
+	if port.Service != "" {
+		output.Service = &ndiffService{
+			Name:      port.Service,
+			Product:   port.Product,
+			Version:   port.Version,
+			ExtraInfo: port.ExtraInfo,
+			Method:    port.Method,
+			CPEs:      port.CPEs,
+		}
+	}
+	for _, script := range port.Scripts {
+		output.Scripts = append(output.Scripts, ndiffScript{script.Name, script.Output})
+	}
+	return output
+}
@@ -0,0 +1,88 @@
+package nmap
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ndiffScans() (old, new Scan) {
+	old = Init()
+	old.NmapVersion = "7.94"
+	old.DisplayArgs = "nmap -sV scanme.nmap.org"
+	old.Stats.Start = time.Date(2024, 3, 4, 9, 15, 40, 0, time.UTC)
+	old.Hosts["45.33.32.156"] = Host{Address: "45.33.32.156", AddressType: "ipv4", State: "up",
+		Hostnames: []Hostname{{"scanme.nmap.org", "user"}},
+		Ports: []Port{
+			{Protocol: "tcp", ID: 22, State: "open", Service: "ssh", Product: "OpenSSH", Version: "6.6.1p1"},
+			{Protocol: "tcp", ID: 80, State: "open", Service: "http",
+				Scripts: []Script{{Name: "http-title", Output: "Go ahead and ScanMe!"}}},
+		}}
+	old.Hosts["10.0.0.5"] = Host{Address: "10.0.0.5", AddressType: "ipv4", State: "up"}
+
+	new = Init()
+	new.NmapVersion = "7.94"
+	new.DisplayArgs = "nmap -sV scanme.nmap.org"
+	new.Stats.Start = time.Date(2024, 3, 5, 14, 2, 11, 0, time.UTC)
+	new.Hosts["45.33.32.156"] = Host{Address: "45.33.32.156", AddressType: "ipv4", State: "up",
+		Hostnames: []Hostname{{"scanme.nmap.org", "user"}},
+		Ports: []Port{
+			{Protocol: "tcp", ID: 22, State: "open", Service: "ssh", Product: "OpenSSH", Version: "8.9p1"},
+			{Protocol: "tcp", ID: 80, State: "open", Service: "http",
+				Scripts: []Script{{Name: "http-title", Output: "Welcome"}}},
+			{Protocol: "tcp", ID: 31337, State: "open", Service: "tcpwrapped"},
+		}}
+	return
+}
+
+func TestScanDiff_Text(t *testing.T) {
+	diff := DiffScans(ndiffScans())
+
+	expected := `-Nmap 7.94 scan initiated Mon Mar  4 09:15:40 2024 as: nmap -sV scanme.nmap.org
++Nmap 7.94 scan initiated Tue Mar  5 14:02:11 2024 as: nmap -sV scanme.nmap.org
+
+-10.0.0.5:
+-Host is up.
+
+ scanme.nmap.org (45.33.32.156):
+ PORT      STATE SERVICE    VERSION
+-22/tcp    open  ssh        OpenSSH 6.6.1p1
++22/tcp    open  ssh        OpenSSH 8.9p1
+ 80/tcp    open  http
+-|_http-title: Go ahead and ScanMe!
++|_http-title: Welcome
++31337/tcp open  tcpwrapped
+
+`
+	if text := diff.Text(); text != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, text)
+	}
+}
+
+func TestScanDiff_XML(t *testing.T) {
+	diff := DiffScans(ndiffScans())
+
+	output, err := diff.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<nmapdiff version="1">`,
+		`<a>` + "\n" + `      <nmaprun scanner="nmap" args="nmap -sV scanme.nmap.org" start="1709543740"`,
+		`<a>` + "\n" + `        <host>` + "\n" + `          <status state="up"></status>` + "\n" + `          <address addr="10.0.0.5"`,
+		`<service name="ssh" product="OpenSSH" version="8.9p1"></service>`,
+		`<script id="http-title" output="Welcome"></script>`,
+	} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Expected the XML to contain %q:\n%s", expected, output)
+		}
+	}
+
+	var parsed struct {
+		HostDiffs []struct{} `xml:"scandiff>hostdiff"`
+	}
+	if err := xml.Unmarshal(output, &parsed); err != nil || len(parsed.HostDiffs) != 2 {
+		t.Errorf("Expected 2 host diffs in valid XML, got %d: %v", len(parsed.HostDiffs), err)
+	}
+}
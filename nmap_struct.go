@@ -4,13 +4,13 @@ import "encoding/xml"
 
 // Nmap is the root object that holds all data
 type rawScan struct {
-	xMLName xml.Name `xml:"nmaprun"`
-
 	DisplayArgs string `xml:"args,attr"`
 	StartTime   string `xml:"start,attr"`
+	Version     string `xml:"version,attr"`
 
 	ScanInfo rawScanInfo `xml:"scaninfo"`
 	Hosts    []rawHost   `xml:"host"`
+	RunStats rawRunStats `xml:"runstats"`
 
 	ScanHosts []string
 	ScanPorts []int
@@ -19,69 +19,98 @@ type rawScan struct {
 
 // ScanInfo holds data about what the was scanned
 type rawScanInfo struct {
-	xMLName xml.Name `xml:"scaninfo"`
-
 	Type        string `xml:"type,attr"`
 	Protocol    string `xml:"protocol,attr"`
 	NumServices string `xml:"numservices,attr"`
 	Services    string `xml:"services,attr"`
 }
 
+// RunStats holds nmap's totals, which are written when the scan finishes
+type rawRunStats struct {
+	Finished rawFinished  `xml:"finished"`
+	Hosts    rawHostStats `xml:"hosts"`
+}
+
+// Finished is when the scan finished and how long it took
+type rawFinished struct {
+	Time    int64   `xml:"time,attr"`
+	Elapsed float64 `xml:"elapsed,attr"`
+	Summary string  `xml:"summary,attr"`
+	Exit    string  `xml:"exit,attr"`
+}
+
+// HostStats is the number of hosts that were up and down
+type rawHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
 // Host holds the information about the port including what address it has and
 // the information about the ports
 type rawHost struct {
-	xMLName xml.Name `xml:"host"`
-
 	Status    rawStatus    `xml:"status"`
-	Address   rawAddress   `xml:"address" json:"address"`
+	Addresses []rawAddress `xml:"address" json:"address"`
 	Hostnames rawHostnames `xml:"hostnames"`
 	Ports     rawPorts     `xml:"ports" json:"ports"`
+	OS        rawOS        `xml:"os"`
 }
 
 // Status gives the status of the host
 type rawStatus struct {
-	xMLName xml.Name `xml:"status"`
-
 	State  string `xml:"state,attr"`
 	Reason string `xml:"reason,attr"`
 }
 
-// Address has the address of the server. This is only used when multiple hosts
-// are scanned at the same time
+// Address has the address of the server. Hosts on the local network also have
+// a MAC address
 type rawAddress struct {
-	xMLName xml.Name `xml:"address"`
-
 	Address     string `xml:"addr,attr"`
 	AddressType string `xml:"addrtype,attr"`
+	Vendor      string `xml:"vendor,attr"`
 }
 
 // Hostnames are a list of hostnames
 type rawHostnames struct {
-	xMLName xml.Name `xml:"hostnames"`
-
 	Hostnames []rawHostname `xml:"hostname"`
 }
 
 // Hostname is an entry that gives the user different hostnames that the IP
 // may own
 type rawHostname struct {
-	xMLName xml.Name `xml:"hostname"`
-
 	Name string `xml:"name,attr"`
 	Type string `xml:"type,attr"`
 }
 
+// OS is the result of OS detection (`-O`)
+type rawOS struct {
+	Matches []rawOSMatch `xml:"osmatch"`
+}
+
+// OSMatch is an operating system that matches the host's fingerprint
+type rawOSMatch struct {
+	Name     string       `xml:"name,attr"`
+	Accuracy int          `xml:"accuracy,attr"`
+	Classes  []rawOSClass `xml:"osclass"`
+}
+
+// OSClass is the vendor, family and generation of an OS match
+type rawOSClass struct {
+	Type       string   `xml:"type,attr"`
+	Vendor     string   `xml:"vendor,attr"`
+	Family     string   `xml:"osfamily,attr"`
+	Generation string   `xml:"osgen,attr"`
+	Accuracy   int      `xml:"accuracy,attr"`
+	CPEs       []string `xml:"cpe"`
+}
+
 // Ports is the array of ports
 type rawPorts struct {
-	xMLName xml.Name `xml:"ports"`
-
 	Ports []rawPort `xml:"port"`
 }
 
 // Port has all of the information about the port in question
 type rawPort struct {
-	xMLName xml.Name `xml:"port"`
-
 	Protocol string `xml:"protocol,attr" json:"protocol"`
 	Port     uint32 `xml:"portid,attr" json:"port"`
 
@@ -92,26 +121,23 @@ type rawPort struct {
 
 // Status gives the status of "open, closed, filtered"
 type rawState struct {
-	xMLName xml.Name `xml:"state"`
-
 	State  string `xml:"state,attr"`
 	Reason string `xml:"reason,attr"`
 }
 
 // Service is the name of the service. Ex: "ssh, rdp, etc."
 type rawService struct {
-	xMLName xml.Name `xml:"service"`
-
-	Name        string `xml:"name,attr"`
-	Method      string `xml:"method,attr"`
-	Product     string `xml:"product"`
-	Fingerprint string `xml:"servicefp"`
+	Name        string   `xml:"name,attr"`
+	Method      string   `xml:"method,attr"`
+	Product     string   `xml:"product,attr"`
+	Version     string   `xml:"version,attr"`
+	ExtraInfo   string   `xml:"extrainfo,attr"`
+	Fingerprint string   `xml:"servicefp,attr"`
+	CPEs        []string `xml:"cpe"`
 }
 
 // Script defines the output for various scripts
 type rawScript struct {
-	xMLName xml.Name `xml:"script"`
-
 	Name   string `xml:"id,attr"`
 	Output string `xml:"output,attr"`
 
@@ -120,11 +146,8 @@ type rawScript struct {
 
 // Element defines an element of a script
 type rawElement struct {
-	xMLName xml.Name `xml:"elem"`
-
-	Key string `xml:"key"`
-
-	Value string
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
 }
 
 func parseXML(inputFile []byte) (*rawScan, error) {
@@ -0,0 +1,138 @@
+package nmaptest
+
+import (
+	"github.com/t94j0/nmap"
+)
+
+// HostBuilder builds an nmap.Host for tests
+type HostBuilder struct {
+	host nmap.Host
+}
+
+// NewHost starts building a host that is up
+func NewHost(address string) *HostBuilder {
+	addressType := "ipv4"
+	for _, c := range address {
+		if c == ':' {
+			addressType = "ipv6"
+			break
+		}
+	}
+	return &HostBuilder{nmap.Host{
+		State:       "up",
+		Address:     address,
+		AddressType: addressType,
+		Hostnames:   []nmap.Hostname{},
+		Ports:       []nmap.Port{},
+	}}
+}
+
+// State sets the host state, E.x. "down"
+func (b *HostBuilder) State(state string) *HostBuilder {
+	b.host.State = state
+	return b
+}
+
+// Hostname adds a hostname found by a reverse lookup
+func (b *HostBuilder) Hostname(name string) *HostBuilder {
+	b.host.Hostnames = append(b.host.Hostnames, nmap.Hostname{Name: name, Type: "PTR"})
+	return b
+}
+
+// OS adds an OS match with the OS family, E.x. "Linux 5.0 - 5.14" and "Linux"
+func (b *HostBuilder) OS(name, family string) *HostBuilder {
+	b.host.OSMatches = append(b.host.OSMatches, nmap.OSMatch{
+		Name:     name,
+		Accuracy: 100,
+		Classes:  []nmap.OSClass{{Vendor: family, Family: family, Accuracy: 100}},
+	})
+	return b
+}
+
+// Ports adds ports to the host
+func (b *HostBuilder) Ports(ports ...nmap.Port) *HostBuilder {
+	b.host.Ports = append(b.host.Ports, ports...)
+	return b
+}
+
+// OpenTCP adds open TCP ports without any service information
+func (b *HostBuilder) OpenTCP(ids ...uint32) *HostBuilder {
+	for _, id := range ids {
+		b.host.Ports = append(b.host.Ports, NewPort("tcp", id).Build())
+	}
+	return b
+}
+
+// OpenUDP adds open UDP ports without any service information
+func (b *HostBuilder) OpenUDP(ids ...uint32) *HostBuilder {
+	for _, id := range ids {
+		b.host.Ports = append(b.host.Ports, NewPort("udp", id).Build())
+	}
+	return b
+}
+
+// Build returns the host
+func (b *HostBuilder) Build() nmap.Host {
+	host := b.host
+	host.Hostnames = append([]nmap.Hostname{}, b.host.Hostnames...)
+	host.Ports = append([]nmap.Port{}, b.host.Ports...)
+	return host
+}
+
+// PortBuilder builds an nmap.Port for tests
+type PortBuilder struct {
+	port nmap.Port
+}
+
+// NewPort starts building a port that is open
+func NewPort(protocol string, id uint32) *PortBuilder {
+	return &PortBuilder{nmap.Port{
+		Protocol: protocol,
+		ID:       id,
+		State:    "open",
+		Scripts:  []nmap.Script{},
+	}}
+}
+
+// State sets the port state, E.x. "closed" or "filtered"
+func (b *PortBuilder) State(state string) *PortBuilder {
+	b.port.State = state
+	return b
+}
+
+// Service sets the service name, E.x. "ssh"
+func (b *PortBuilder) Service(service string) *PortBuilder {
+	b.port.Service = service
+	b.port.Method = "probed"
+	return b
+}
+
+// Version sets the product and version found by version detection, E.x.
+// "OpenSSH" and "8.9p1"
+func (b *PortBuilder) Version(product, version string) *PortBuilder {
+	b.port.Product = product
+	b.port.Version = version
+	return b
+}
+
+// Script adds the output of an NSE script
+func (b *PortBuilder) Script(name, output string, elements ...nmap.Element) *PortBuilder {
+	b.port.Scripts = append(b.port.Scripts, nmap.Script{Name: name, Output: output, Elements: elements})
+	return b
+}
+
+// Build returns the port
+func (b *PortBuilder) Build() nmap.Port {
+	port := b.port
+	port.Scripts = append([]nmap.Script{}, b.port.Scripts...)
+	return port
+}
+
+// NewScan returns a Scan holding the hosts, as if they were found by nmap
+func NewScan(hosts ...nmap.Host) nmap.Scan {
+	scan := nmap.Init()
+	for _, host := range hosts {
+		scan.Hosts[host.Address] = host
+	}
+	return scan
+}
@@ -0,0 +1,49 @@
+package nmaptest
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strings"
+)
+
+// fixtures are nmap XML output, along with the stderr of runs that printed
+// warnings. The command line of each is in `fixtures/README.md`.
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// Fixture returns the recorded Response for a fixture. The name is the file
+// name without the extension, E.x. "scanme-version". If the fixture has a
+// `.stderr` file, it is used for Stderr.
+func Fixture(name string) (Response, error) {
+	stdout, err := fixtures.ReadFile(path.Join("fixtures", name+".xml"))
+	if err != nil {
+		return Response{}, err
+	}
+	stderr, _ := fixtures.ReadFile(path.Join("fixtures", name+".stderr"))
+	return Response{Stdout: stdout, Stderr: stderr}, nil
+}
+
+// MustFixture is like Fixture but panics when the fixture doesn't exist
+func MustFixture(name string) Response {
+	response, err := Fixture(name)
+	if err != nil {
+		panic(err)
+	}
+	return response
+}
+
+// Fixtures returns the name of every fixture
+func Fixtures() []string {
+	entries, _ := fixtures.ReadDir("fixtures")
+
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".xml") {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".xml"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,118 @@
+// Package nmaptest helps with testing code that uses the nmap package without
+// running nmap.
+//
+// FakeRunner replays recorded nmap output instead of starting nmap. Set it as
+// the Runner of a Scan with SetRunner, and Run will parse the recorded output.
+// The fixtures directory has real nmap XML output that can be replayed with
+// Fixture.
+//
+// NewHost and NewPort build Host and Port values for testing code that works
+// with scan results.
+package nmaptest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/t94j0/nmap"
+)
+
+// Response is the recorded output of one nmap run
+type Response struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// FakeRunner is an nmap.Runner that replays a Response for each list of
+// arguments instead of running nmap
+type FakeRunner struct {
+	// Default is replayed when no Response was added for the arguments. If it
+	// is nil, Start returns an error.
+	Default *Response
+	// RunAs is the privileges reported to Scans using the runner
+	RunAs nmap.Privileges
+
+	mu        sync.Mutex
+	responses map[string]Response
+	calls     [][]string
+}
+
+// NewFakeRunner creates a FakeRunner without any responses
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{responses: make(map[string]Response)}
+}
+
+// Respond adds the Response that is replayed when nmap is started with the
+// arguments
+func (f *FakeRunner) Respond(args []string, response Response) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.responses == nil {
+		f.responses = make(map[string]Response)
+	}
+	f.responses[argsKey(args)] = response
+	return f
+}
+
+// RespondTo adds the Response that is replayed when the scan is run. The scan
+// must use this runner, since the arguments depend on its privileges.
+func (f *FakeRunner) RespondTo(scan nmap.Scan, response Response) (*FakeRunner, error) {
+	args, err := scan.SetRunner(f).CreateNmapArgs()
+	if err != nil {
+		return f, err
+	}
+	return f.Respond(args, response), nil
+}
+
+// Start replays the Response recorded for the arguments
+func (f *FakeRunner) Start(args []string) (nmap.Process, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, append([]string{}, args...))
+	response, ok := f.responses[argsKey(args)]
+	if !ok {
+		if f.Default == nil {
+			return nil, fmt.Errorf("nmaptest: no response for arguments %q", args)
+		}
+		response = *f.Default
+	}
+	return &fakeProcess{
+		stdout:   bytes.NewReader(response.Stdout),
+		stderr:   bytes.NewReader(response.Stderr),
+		exitCode: response.ExitCode,
+	}, nil
+}
+
+// Privileges returns the privileges set on the runner, so that the arguments
+// made for Scans don't depend on the user running the tests
+func (f *FakeRunner) Privileges() nmap.Privileges {
+	return f.RunAs
+}
+
+// Calls returns the arguments of every time nmap was started
+func (f *FakeRunner) Calls() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string{}, f.calls...)
+}
+
+// argsKey joins the arguments into a map key
+func argsKey(args []string) string {
+	return strings.Join(args, "\x00")
+}
+
+// fakeProcess is the Process returned by FakeRunner
+type fakeProcess struct {
+	stdout   io.Reader
+	stderr   io.Reader
+	exitCode int
+}
+
+func (p *fakeProcess) Stdout() io.Reader  { return p.stdout }
+func (p *fakeProcess) Stderr() io.Reader  { return p.stderr }
+func (p *fakeProcess) Wait() (int, error) { return p.exitCode, nil }
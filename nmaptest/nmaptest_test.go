@@ -0,0 +1,163 @@
+package nmaptest
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/t94j0/nmap"
+)
+
+func TestFixtures(t *testing.T) {
+	for _, name := range Fixtures() {
+		runner := NewFakeRunner()
+		response := MustFixture(name)
+		runner.Default = &response
+
+		if _, err := nmap.Init().AddHosts("localhost").SetRunner(runner).Run(); err != nil {
+			t.Errorf("Fixture %s could not be parsed: %s", name, err)
+		}
+	}
+}
+
+func TestFakeRunner_RespondTo(t *testing.T) {
+	runner := NewFakeRunner()
+	scan := nmap.Init().AddHosts("scanme.nmap.org").AddFlags("-sV", "-sC", "-T4")
+	if _, err := runner.RespondTo(scan, MustFixture("scanme-version")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := scan.SetRunner(runner).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, ok := result.GetHost("scanme.nmap.org")
+	if !ok {
+		t.Fatal("scanme.nmap.org was not found")
+	}
+	if host.Address != "45.33.32.156" || len(host.Ports) != 4 {
+		t.Errorf("Wrong host parsed: %+v", host)
+	}
+	if len(runner.Calls()) != 1 {
+		t.Errorf("Expected 1 call, got %d", len(runner.Calls()))
+	}
+
+	if _, err := nmap.Init().AddHosts("example.com").SetRunner(runner).Run(); err == nil {
+		t.Errorf("Arguments without a response should fail")
+	}
+}
+
+func TestFakeRunner_exitcode(t *testing.T) {
+	runner := NewFakeRunner()
+	runner.Default = &Response{Stderr: []byte("nmap: unrecognized option '--bad'\n"), ExitCode: 255}
+
+	if _, err := nmap.Init().AddHosts("localhost").SetRunner(runner).Run(); err == nil {
+		t.Errorf("Non-zero exit code should fail the scan")
+	}
+}
+
+func TestNewHost(t *testing.T) {
+	host := NewHost("10.0.0.1").
+		Hostname("db.lab").
+		OpenTCP(22).
+		Ports(NewPort("tcp", 5432).Service("postgresql").Script("banner", "PostgreSQL").Build()).
+		Build()
+
+	scan := NewScan(host)
+	found, ok := scan.GetHost("db.lab")
+	if !ok || len(found.Ports) != 2 || found.Ports[1].Scripts[0].Name != "banner" {
+		t.Errorf("Wrong host built: %+v", found)
+	}
+}
+
+// fixtureRun is the part of a fixture that TestFixtures_consistent checks
+type fixtureRun struct {
+	Args     string `xml:"args,attr"`
+	ScanInfo []struct {
+		NumServices int    `xml:"numservices,attr"`
+		Services    string `xml:"services,attr"`
+	} `xml:"scaninfo"`
+	Hosts []struct {
+		Ports []struct{} `xml:"ports>port"`
+		Extra []struct {
+			Count   int `xml:"count,attr"`
+			Reasons []struct {
+				Count int    `xml:"count,attr"`
+				Ports string `xml:"ports,attr"`
+			} `xml:"extrareasons"`
+		} `xml:"ports>extraports"`
+	} `xml:"host"`
+	Stats struct {
+		Up    int `xml:"up,attr"`
+		Down  int `xml:"down,attr"`
+		Total int `xml:"total,attr"`
+	} `xml:"runstats>hosts"`
+}
+
+// countPorts counts the ports in an nmap port list, E.x. `1-21,23,80`
+func countPorts(t *testing.T, list string) (count int) {
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			t.Fatalf("Bad port list %q", list)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				t.Fatalf("Bad port list %q", list)
+			}
+		}
+		count += last - first + 1
+	}
+	return
+}
+
+func TestFixtures_consistent(t *testing.T) {
+	for _, name := range Fixtures() {
+		var run fixtureRun
+		if err := xml.Unmarshal(MustFixture(name).Stdout, &run); err != nil {
+			t.Fatalf("Fixture %s: %v", name, err)
+		}
+
+		if run.Stats.Up+run.Stats.Down != run.Stats.Total || len(run.Hosts) != run.Stats.Up {
+			t.Errorf("Fixture %s: host counts don't match the hosts: %+v", name, run.Stats)
+		}
+		if len(run.ScanInfo) != 1 {
+			continue
+		}
+		info := run.ScanInfo[0]
+		if countPorts(t, info.Services) != info.NumServices {
+			t.Errorf("Fixture %s: numservices %d doesn't match %q", name, info.NumServices, info.Services)
+		}
+		for _, arg := range strings.Fields(run.Args) {
+			if strings.HasPrefix(arg, "-p") {
+				ports := strings.NewReplacer("U:", "", "T:", "").Replace(arg[2:])
+				if ports != info.Services {
+					t.Errorf("Fixture %s: scanned %q, but %s was requested", name, info.Services, arg)
+				}
+			}
+		}
+
+		for _, host := range run.Hosts {
+			count := len(host.Ports)
+			for _, extra := range host.Extra {
+				count += extra.Count
+				reasons := 0
+				for _, reason := range extra.Reasons {
+					reasons += reason.Count
+					if reason.Ports != "" && countPorts(t, reason.Ports) != reason.Count {
+						t.Errorf("Fixture %s: extrareasons count %d doesn't match %q", name, reason.Count, reason.Ports)
+					}
+				}
+				if reasons != extra.Count {
+					t.Errorf("Fixture %s: extrareasons add up to %d, not %d", name, reasons, extra.Count)
+				}
+			}
+			if count != info.NumServices {
+				t.Errorf("Fixture %s: a host has %d ports, but %d were scanned", name, count, info.NumServices)
+			}
+		}
+	}
+}
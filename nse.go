@@ -0,0 +1,204 @@
+package nmap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScriptArgs holds arguments given to NSE scripts. Values can be strings,
+// booleans, numbers, slices (which become NSE lists) or ScriptArgs (which
+// become NSE tables).
+//
+// E.x. ScriptArgs{"http.useragent": "Mozilla", "creds.global": "admin:admin"}
+type ScriptArgs map[string]interface{}
+
+// scriptArgSpecial are the characters that have a meaning in the
+// `--script-args` syntax. Values containing them must be quoted.
+const scriptArgSpecial = "{}=,\"'\\"
+
+// AddScripts selects NSE scripts to run. Each selection can be a script name,
+// a category, a file or directory path, a glob (E.x. `http-*`) or a boolean
+// expression of those (E.x. `default and not intrusive`). This is similar to
+// using `--script <selection1>,<selection2>...`
+func (s Scan) AddScripts(scripts ...string) Scan {
+	s = s.checkScripts("AddScripts", scripts)
+	s.configScripts = append(s.configScripts, scripts...)
+	return s
+}
+
+// SetScripts sets which NSE scripts are run. See AddScripts for which
+// selections can be used.
+func (s Scan) SetScripts(scripts ...string) Scan {
+	s = s.clearErrs("SetScripts")
+	s = s.checkScripts("SetScripts", scripts)
+	s.configScripts = scripts
+	return s
+}
+
+// SetScriptArg sets one argument given to the NSE scripts. See ScriptArgs for
+// which values can be used. Similar to using `--script-args <name>=<value>`
+func (s Scan) SetScriptArg(name string, value interface{}) Scan {
+	return s.AddScriptArgs(ScriptArgs{name: value})
+}
+
+// AddScriptArgs sets a list of arguments given to the NSE scripts. Arguments
+// that were already set are replaced.
+func (s Scan) AddScriptArgs(args ScriptArgs) Scan {
+	merged := ScriptArgs{}
+	for name, value := range s.configScriptArgs {
+		merged[name] = value
+	}
+	for name, value := range args {
+		if name == "" {
+			s = s.addErr("AddScriptArgs", name, errors.New("Script argument name must not be empty"))
+			continue
+		}
+		if _, err := formatScriptArgValue(value); err != nil {
+			s = s.addErr("AddScriptArgs", name, err)
+			continue
+		}
+		merged[name] = value
+	}
+	s.configScriptArgs = merged
+	return s
+}
+
+// SetScriptArgsFile loads NSE script arguments from a file. Similar to using
+// `--script-args-file <path>`
+func (s Scan) SetScriptArgsFile(path string) Scan {
+	s.configScriptArgsFile = path
+	return s
+}
+
+// SetScriptTimeout sets the maximum time that a single NSE script can run for.
+// Similar to using `--script-timeout <time>`
+func (s Scan) SetScriptTimeout(timeout time.Duration) Scan {
+	s = s.clearErrs("SetScriptTimeout")
+	if timeout < 0 {
+		return s.addErr("SetScriptTimeout", timeout.String(), errors.New("Timeout must not be negative"))
+	}
+	s.configScriptTimeout = timeout
+	return s
+}
+
+// checkScripts records an error for each script selection that can't be
+// passed to `--script`
+func (s Scan) checkScripts(method string, scripts []string) Scan {
+	for _, script := range scripts {
+		if strings.TrimSpace(script) == "" {
+			s = s.addErr(method, script, errors.New("Script selection must not be empty"))
+		} else if strings.Contains(script, ",") {
+			s = s.addErr(method, script, errors.New("Script selections must be given as separate arguments instead of separated by commas"))
+		}
+	}
+	return s
+}
+
+// scriptArgs returns the arguments that configure NSE
+func (s Scan) scriptArgs() (args []string) {
+	if len(s.configScripts) != 0 {
+		args = append(args, "--script", strings.Join(s.configScripts, ","))
+	}
+	if len(s.configScriptArgs) != 0 {
+		formatted, _ := formatScriptArgs(s.configScriptArgs)
+		args = append(args, "--script-args", formatted)
+	}
+	if s.configScriptArgsFile != "" {
+		args = append(args, "--script-args-file", s.configScriptArgsFile)
+	}
+	if s.configScriptTimeout != 0 {
+		args = append(args, "--script-timeout", formatDuration(s.configScriptTimeout))
+	}
+	return
+}
+
+// formatScriptArgs converts the arguments into the `--script-args` syntax. Keys
+// are sorted so that the same arguments always give the same output.
+func formatScriptArgs(args ScriptArgs) (string, error) {
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		value, err := formatScriptArgValue(args[name])
+		if err != nil {
+			return "", err
+		}
+		pairs = append(pairs, quoteScriptArg(name)+"="+value)
+	}
+	return strings.Join(pairs, ","), nil
+}
+
+// formatScriptArgValue converts a single value into the `--script-args` syntax
+func formatScriptArgValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return quoteScriptArg(v), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case ScriptArgs:
+		table, err := formatScriptArgs(v)
+		return "{" + table + "}", err
+	case map[string]interface{}:
+		table, err := formatScriptArgs(ScriptArgs(v))
+		return "{" + table + "}", err
+	case nil:
+		return "", errors.New("Script argument value must not be nil")
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(value), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			item, err := formatScriptArgValue(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+		}
+		return "{" + strings.Join(items, ",") + "}", nil
+	}
+
+	return "", fmt.Errorf("Script argument value of type %T is not supported", value)
+}
+
+// quoteScriptArg quotes a name or value when it has characters that NSE would
+// otherwise read as part of the `--script-args` syntax
+func quoteScriptArg(value string) string {
+	if value != "" && !strings.ContainsAny(value, scriptArgSpecial) &&
+		strings.TrimSpace(value) == value {
+		return value
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// formatDuration converts a duration into nmap's time format. Whole seconds are
+// written as seconds, otherwise milliseconds are used.
+func formatDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
@@ -0,0 +1,100 @@
+package nmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatScriptArgs(t *testing.T) {
+	args := ScriptArgs{
+		"http.useragent": "Mozilla/5.0 (X11, Linux)",
+		"creds.global":   "admin:p=ss",
+		"smbdomain":      "CORP",
+		"unsafe":         true,
+		"http-title.url": "/",
+		"vulns.showall":  1,
+		"userdb":         []string{"root", "a\"b"},
+		"tls":            ScriptArgs{"version": "1.2", "ciphers": []string{"a,b"}},
+	}
+	expected := `creds.global="admin:p=ss",` +
+		`http-title.url=/,` +
+		`http.useragent="Mozilla/5.0 (X11, Linux)",` +
+		`smbdomain=CORP,` +
+		`tls={ciphers={"a,b"},version=1.2},` +
+		`unsafe=true,` +
+		`userdb={root,"a\"b"},` +
+		`vulns.showall=1`
+
+	formatted, err := formatScriptArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, formatted)
+	}
+}
+
+func TestScan_AddScripts(t *testing.T) {
+	args, err := Init().
+		AddHosts("localhost").
+		AddScripts("default and not intrusive", "http-*").
+		SetScriptArg("http.useragent", "test agent").
+		SetScriptTimeout(90 * time.Second).
+		CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joined := strings.Join(args, "|")
+	for _, expected := range []string{
+		"--script|default and not intrusive,http-*",
+		"--script-args|http.useragent=test agent",
+		"--script-timeout|90s",
+	} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Arguments %v should contain %s", args, expected)
+		}
+	}
+}
+
+func TestScan_AddScripts_invalid(t *testing.T) {
+	err := Init().
+		AddHosts("localhost").
+		AddScripts("http-title,ssl-cert").
+		SetScriptArg("channel", make(chan int)).
+		Validate()
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", err)
+	}
+
+	err = Init().
+		AddHosts("localhost").
+		AddScripts("http-title").
+		AddFlags("--script", "ssl-cert").
+		Validate()
+	if err == nil {
+		t.Errorf("--script flag was accepted along with AddScripts")
+	}
+
+	err = Init().
+		AddHosts("localhost").
+		AddScripts("http-title").
+		AddFlags("--script-timeout", "30s", "--script-args", "http.useragent=test").
+		Validate()
+	if err != nil {
+		t.Errorf("Script flags not set by the *Script* functions should be allowed: %v", err)
+	}
+
+	err = Init().
+		AddHosts("localhost").
+		SetScriptTimeout(time.Minute).
+		AddFlags("--script-timeout", "30s").
+		Validate()
+	var flagErr *FlagError
+	if !errors.As(err, &flagErr) || flagErr.Flag != "--script-timeout" {
+		t.Errorf("--script-timeout flag was accepted along with SetScriptTimeout: %v", err)
+	}
+}
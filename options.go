@@ -0,0 +1,329 @@
+package nmap
+
+import (
+	"strings"
+)
+
+// Scan modes. Only one mode can be used in a single nmap invocation. A port
+// scan can still combine several protocols (E.x. `-sS -sU`)
+const (
+	modePortScan = "port scan"
+	modePing     = "ping scan"
+	modeList     = "list scan"
+)
+
+// Groups of flags where only one flag of the group may be used
+const (
+	groupTCPScan = "TCP scan type"
+	groupTiming  = "timing template"
+	groupPrivs   = "privilege mode"
+)
+
+// nmapOption describes a single nmap command-line option
+type nmapOption struct {
+	// Name is the flag as written on the command line
+	Name string
+	// Arity is the number of arguments the flag takes. If the flag can have
+	// its argument attached to it (E.x. `-p80`), Attached is set.
+	Arity    int
+	Attached bool
+	// Prefix is set for flags that take an optional value directly after the
+	// flag name, such as `-PS22,80` or `-T4`
+	Prefix bool
+	// Group is the mutually-exclusive group the flag belongs to
+	Group string
+	// Mode is the scan mode the flag selects
+	Mode string
+	// Privileged is set when nmap requires root to use the flag
+	Privileged bool
+	// MinVersion is the first nmap version that has the flag. It is empty for
+	// flags that every supported nmap version has.
+	MinVersion string
+	// NSE is set for flags that need nmap to be built with Lua
+	NSE bool
+	// Optional is set for flags that only tune the scan, so they can be left
+	// out when nmap doesn't support them
+	Optional bool
+}
+
+// nmapOptions is the table of options that the library understands. It follows
+// the option summary printed by `nmap --help` and the options in the nmap man
+// page, including older aliases that nmap still accepts.
+var nmapOptions = []nmapOption{
+	// Target specification
+	{Name: "-iL", Arity: 1},
+	{Name: "-iR", Arity: 1},
+	{Name: "--exclude", Arity: 1},
+	{Name: "--excludefile", Arity: 1},
+
+	// Host discovery
+	{Name: "-sL", Mode: modeList},
+	{Name: "-sn", Mode: modePing},
+	{Name: "-sP", Mode: modePing},
+	{Name: "-Pn"},
+	{Name: "-PN"},
+	{Name: "-P0"},
+	{Name: "-PS", Prefix: true},
+	{Name: "-PA", Prefix: true},
+	{Name: "-PU", Prefix: true},
+	{Name: "-PY", Prefix: true, Privileged: true},
+	{Name: "-PE", Privileged: true},
+	{Name: "-PP", Privileged: true},
+	{Name: "-PM", Privileged: true},
+	{Name: "-PO", Prefix: true, Privileged: true},
+	{Name: "-PR", Privileged: true},
+	{Name: "--disable-arp-ping"},
+	{Name: "-n"},
+	{Name: "-R"},
+	{Name: "--dns-servers", Arity: 1},
+	{Name: "--system-dns"},
+	{Name: "--resolve-all", MinVersion: "7.70"},
+	{Name: "--unique", MinVersion: "7.80"},
+	{Name: "--discovery-ignore-rst", MinVersion: "7.80", Optional: true},
+	{Name: "--traceroute", Privileged: true},
+
+	// Scan techniques
+	{Name: "-sS", Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "-sT", Group: groupTCPScan, Mode: modePortScan},
+	{Name: "-sA", Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "-sW", Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "-sM", Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "-sN", Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "-sF", Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "-sX", Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "--scanflags", Arity: 1, Privileged: true},
+	{Name: "-sI", Arity: 1, Group: groupTCPScan, Mode: modePortScan, Privileged: true},
+	{Name: "-sU", Mode: modePortScan, Privileged: true},
+	{Name: "-sY", Mode: modePortScan, Privileged: true},
+	{Name: "-sZ", Mode: modePortScan, Privileged: true},
+	{Name: "-sO", Mode: modePortScan, Privileged: true},
+	{Name: "-b", Arity: 1, Mode: modePortScan},
+	{Name: "-sR"},
+
+	// Port specification and scan order
+	{Name: "-p", Arity: 1, Attached: true},
+	{Name: "--exclude-ports", Arity: 1},
+	{Name: "-F"},
+	{Name: "-r"},
+	{Name: "--top-ports", Arity: 1},
+	{Name: "--port-ratio", Arity: 1},
+	{Name: "--allports"},
+
+	// Service/version detection
+	{Name: "-sV"},
+	{Name: "--version-intensity", Arity: 1},
+	{Name: "--version-light"},
+	{Name: "--version-all"},
+	{Name: "--version-trace"},
+
+	// Script scan
+	{Name: "-sC", NSE: true},
+	{Name: "--script", Arity: 1, NSE: true},
+	{Name: "--script-args", Arity: 1, NSE: true},
+	{Name: "--script-args-file", Arity: 1, NSE: true},
+	{Name: "--script-trace", NSE: true},
+	{Name: "--script-updatedb", NSE: true},
+	{Name: "--script-help", Arity: 1, NSE: true},
+	{Name: "--script-timeout", Arity: 1, NSE: true},
+
+	// OS detection
+	{Name: "-O", Privileged: true},
+	{Name: "--osscan-limit", Privileged: true},
+	{Name: "--osscan-guess", Privileged: true},
+	{Name: "--fuzzy", Privileged: true},
+	{Name: "--max-os-tries", Arity: 1, Privileged: true},
+
+	// Timing and performance
+	{Name: "-T", Prefix: true, Group: groupTiming},
+	{Name: "--min-hostgroup", Arity: 1},
+	{Name: "--max-hostgroup", Arity: 1},
+	{Name: "--min-parallelism", Arity: 1},
+	{Name: "--max-parallelism", Arity: 1},
+	{Name: "--min-rtt-timeout", Arity: 1},
+	{Name: "--max-rtt-timeout", Arity: 1},
+	{Name: "--initial-rtt-timeout", Arity: 1},
+	{Name: "--max-retries", Arity: 1},
+	{Name: "--host-timeout", Arity: 1},
+	{Name: "--scan-delay", Arity: 1},
+	{Name: "--max-scan-delay", Arity: 1},
+	{Name: "--min-rate", Arity: 1},
+	{Name: "--max-rate", Arity: 1},
+	{Name: "--defeat-rst-ratelimit", Optional: true},
+	{Name: "--defeat-icmp-ratelimit", MinVersion: "7.80", Optional: true},
+	{Name: "--nsock-engine", Arity: 1},
+
+	// Firewall/IDS evasion and spoofing
+	{Name: "-f", Privileged: true},
+	{Name: "--mtu", Arity: 1, Privileged: true},
+	{Name: "-D", Arity: 1, Privileged: true},
+	{Name: "-S", Arity: 1, Privileged: true},
+	{Name: "-e", Arity: 1},
+	{Name: "-g", Arity: 1, Privileged: true},
+	{Name: "--source-port", Arity: 1, Privileged: true},
+	{Name: "--proxies", Arity: 1},
+	{Name: "--proxy", Arity: 1},
+	{Name: "--data", Arity: 1, Privileged: true},
+	{Name: "--data-string", Arity: 1, Privileged: true},
+	{Name: "--data-length", Arity: 1, Privileged: true},
+	{Name: "--ip-options", Arity: 1, Privileged: true},
+	{Name: "--ttl", Arity: 1, Privileged: true},
+	{Name: "--spoof-mac", Arity: 1, Privileged: true},
+	{Name: "--badsum", Privileged: true},
+	{Name: "--adler32", Privileged: true},
+	{Name: "--randomize-hosts"},
+	{Name: "--rH"},
+
+	// Output
+	{Name: "-v", Prefix: true},
+	{Name: "-d", Prefix: true},
+	{Name: "--reason"},
+	{Name: "--open"},
+	{Name: "--packet-trace"},
+	{Name: "--log-errors"},
+	{Name: "--append-output"},
+	{Name: "--noninteractive", MinVersion: "7.80", Optional: true},
+	{Name: "--stylesheet", Arity: 1},
+	{Name: "--webxml"},
+	{Name: "--no-stylesheet"},
+	{Name: "--stats-every", Arity: 1},
+
+	// Misc
+	{Name: "-6"},
+	{Name: "-A", Privileged: true},
+	{Name: "--datadir", Arity: 1},
+	{Name: "--servicedb", Arity: 1},
+	{Name: "--versiondb", Arity: 1},
+	{Name: "--send-eth", Privileged: true},
+	{Name: "--send-ip", Privileged: true},
+	{Name: "--route-dst", Arity: 1},
+	{Name: "--release-memory"},
+	{Name: "--privileged", Group: groupPrivs},
+	{Name: "--unprivileged", Group: groupPrivs},
+}
+
+// lookupOption finds the option that the flag refers to. The second return
+// value is the argument that was attached to the flag, if any.
+func lookupOption(flag string) (opt nmapOption, attached string, ok bool) {
+	name := flag
+	if strings.HasPrefix(flag, "--") {
+		if i := strings.Index(flag, "="); i != -1 {
+			name, attached = flag[:i], flag[i+1:]
+		}
+	}
+
+	for _, o := range nmapOptions {
+		if o.Name == name {
+			if attached != "" && o.Arity == 0 {
+				return nmapOption{}, "", false
+			}
+			return o, attached, true
+		}
+	}
+
+	// Short options can have the value glued onto the flag
+	if strings.HasPrefix(flag, "--") {
+		return nmapOption{}, "", false
+	}
+	for _, o := range nmapOptions {
+		if (o.Attached || o.Prefix) && strings.HasPrefix(flag, o.Name) {
+			return o, flag[len(o.Name):], true
+		}
+	}
+
+	return nmapOption{}, "", false
+}
+
+// parsedFlag is a flag along with the argument given to it
+type parsedFlag struct {
+	option nmapOption
+	flag   string
+	arg    string
+}
+
+// parseFlags splits a list of flags into each flag and its argument. Flags
+// that can't be parsed are left out of the list and reported as errors.
+func parseFlags(flags []string) (parsed []parsedFlag, errs []error) {
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		if !strings.HasPrefix(flag, "-") {
+			errs = append(errs, &FlagError{flag, "is not a flag and does not belong to a flag that takes an argument"})
+			continue
+		}
+
+		opt, arg, ok := lookupOption(flag)
+		if !ok {
+			errs = append(errs, &FlagError{flag, "is not a known nmap option"})
+			continue
+		}
+
+		if opt.Arity == 1 && arg == "" {
+			if i+1 >= len(flags) {
+				errs = append(errs, &FlagError{flag, "requires an argument"})
+				continue
+			}
+			i++
+			arg = flags[i]
+		}
+
+		parsed = append(parsed, parsedFlag{opt, flag, arg})
+	}
+
+	return
+}
+
+// checkConflicts makes sure that flags from the same group or from different
+// scan modes are not used together
+func checkConflicts(flags []parsedFlag) (errs []error) {
+	groups := make(map[string]string)
+	mode, modeFlag := "", ""
+
+	for _, f := range flags {
+		if f.option.Group != "" {
+			if other, ok := groups[f.option.Group]; ok && other != f.flag {
+				errs = append(errs, &FlagError{f.flag, "cannot be used with " + other + ", only one " + f.option.Group + " is allowed"})
+			} else {
+				groups[f.option.Group] = f.flag
+			}
+		}
+		if f.option.Mode != "" {
+			if mode != "" && mode != f.option.Mode {
+				errs = append(errs, &FlagError{f.flag, "selects a " + f.option.Mode + " which cannot be combined with the " + mode + " selected by " + modeFlag})
+			} else {
+				mode, modeFlag = f.option.Mode, f.flag
+			}
+		}
+	}
+
+	return
+}
+
+// scanMode returns the scan mode selected by the flags, or an empty string if
+// no mode has been selected
+func scanMode(flags []parsedFlag) string {
+	for _, f := range flags {
+		if f.option.Mode != "" {
+			return f.option.Mode
+		}
+	}
+	return ""
+}
+
+// hasGroup returns true when one of the flags belongs to the group
+func hasGroup(flags []parsedFlag, group string) bool {
+	for _, f := range flags {
+		if f.option.Group == group {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlag returns true when the option name was used
+func hasFlag(flags []parsedFlag, name string) bool {
+	for _, f := range flags {
+		if f.option.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package nmap
+
+import "testing"
+
+// documentedFlags are the options from `nmap --help` and the nmap man page,
+// along with an argument for the ones that take one. The output flags that
+// the library doesn't allow and flags that only print information and exit,
+// such as `-V` and `-h`, are left out.
+var documentedFlags = [][]string{
+	// Target specification
+	{"-iL", "targets.txt"}, {"-iR", "10"}, {"--exclude", "10.0.0.1"}, {"--excludefile", "exclude.txt"},
+
+	// Host discovery
+	{"-sL"}, {"-sn"}, {"-sP"}, {"-Pn"}, {"-PN"}, {"-P0"}, {"-PS22,80"}, {"-PA80"}, {"-PU53"}, {"-PY"},
+	{"-PE"}, {"-PP"}, {"-PM"}, {"-PO1,2"}, {"-PR"}, {"--disable-arp-ping"}, {"--discovery-ignore-rst"},
+	{"-n"}, {"-R"}, {"--dns-servers", "1.1.1.1"}, {"--system-dns"}, {"--resolve-all"}, {"--unique"},
+	{"--traceroute"},
+
+	// Scan techniques
+	{"-sS"}, {"-sT"}, {"-sA"}, {"-sW"}, {"-sM"}, {"-sU"}, {"-sN"}, {"-sF"}, {"-sX"},
+	{"--scanflags", "URGACK"}, {"-sI", "zombie"}, {"-sY"}, {"-sZ"}, {"-sO"}, {"-b", "ftp.example.com"},
+	{"-sR"},
+
+	// Port specification and scan order
+	{"-p", "22"}, {"-p22"}, {"--exclude-ports", "25"}, {"-F"}, {"-r"}, {"--top-ports", "100"},
+	{"--port-ratio", "0.1"}, {"--allports"},
+
+	// Service/version detection
+	{"-sV"}, {"--version-intensity", "5"}, {"--version-light"}, {"--version-all"}, {"--version-trace"},
+
+	// Script scan
+	{"-sC"}, {"--script", "default"}, {"--script=default"}, {"--script-args", "a=1"},
+	{"--script-args-file", "args.txt"}, {"--script-trace"}, {"--script-updatedb"},
+	{"--script-help", "http-title"}, {"--script-timeout", "1m"},
+
+	// OS detection
+	{"-O"}, {"--osscan-limit"}, {"--osscan-guess"}, {"--fuzzy"}, {"--max-os-tries", "2"},
+
+	// Timing and performance
+	{"-T4"}, {"--min-hostgroup", "16"}, {"--max-hostgroup", "64"},
+	{"--min-parallelism", "10"}, {"--max-parallelism", "100"}, {"--min-rtt-timeout", "100ms"},
+	{"--max-rtt-timeout", "1s"}, {"--initial-rtt-timeout", "500ms"}, {"--max-retries", "2"},
+	{"--host-timeout", "30m"}, {"--scan-delay", "1s"}, {"--max-scan-delay", "2s"},
+	{"--min-rate", "100"}, {"--max-rate", "1000"}, {"--defeat-rst-ratelimit"},
+	{"--defeat-icmp-ratelimit"}, {"--nsock-engine", "epoll"},
+
+	// Firewall/IDS evasion and spoofing
+	{"-f"}, {"--mtu", "16"}, {"-D", "RND:10"}, {"-S", "10.0.0.2"}, {"-e", "eth0"}, {"-g", "53"},
+	{"--source-port", "53"}, {"--proxies", "http://proxy:8080"}, {"--proxy", "http://proxy:8080"},
+	{"--data", "deadbeef"}, {"--data-string", "hello"}, {"--data-length", "20"},
+	{"--ip-options", "R"}, {"--ttl", "64"}, {"--spoof-mac", "0"}, {"--badsum"}, {"--adler32"},
+	{"--randomize-hosts"}, {"--rH"},
+
+	// Output
+	{"-v"}, {"-vv"}, {"-v3"}, {"-d"}, {"-d2"}, {"--reason"}, {"--open"},
+	{"--packet-trace"}, {"--append-output"},
+	{"--noninteractive"}, {"--stylesheet", "nmap.xsl"}, {"--webxml"}, {"--no-stylesheet"},
+	{"--log-errors"}, {"--stats-every", "5s"},
+
+	// Misc
+	{"-6"}, {"-A"}, {"--datadir", "/usr/share/nmap"}, {"--servicedb", "services"},
+	{"--versiondb", "probes"}, {"--send-eth"}, {"--send-ip"}, {"--privileged"}, {"--unprivileged"},
+	{"--route-dst", "10.0.0.1"}, {"--release-memory"},
+}
+
+func TestParseFlags_documented(t *testing.T) {
+	for _, flags := range documentedFlags {
+		parsed, errs := parseFlags(flags)
+		if len(errs) != 0 {
+			t.Errorf("%q should be accepted: %v", flags, errs)
+			continue
+		}
+		if len(parsed) != 1 {
+			t.Errorf("%q should be parsed as one flag, got %v", flags, parsed)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package nmap
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// DefaultMaxStderr is how many bytes of nmap's stderr are kept by default.
+// Once more is written, the oldest output is dropped.
+const DefaultMaxStderr = 64 * 1024
+
+// OnStderr sets a function that is called with each line nmap writes to
+// stderr while the scan is running. The line doesn't include the newline.
+func (s Scan) OnStderr(callback func(line string)) Scan {
+	s.configOnStderr = callback
+	return s
+}
+
+// SetMaxStderr sets how many bytes of nmap's stderr are kept for errors and
+// recordings. Lines are still given to the OnStderr callback when they are
+// dropped.
+func (s Scan) SetMaxStderr(max int) Scan {
+	s.configMaxStderr = max
+	return s
+}
+
+// tailBuffer keeps the last max bytes written to it. Output is only moved to
+// the front once twice max is buffered, so each byte is copied at most once.
+type tailBuffer struct {
+	max  int
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > 2*b.max {
+		b.data = b.data[:copy(b.data, b.data[len(b.data)-b.max:])]
+	}
+	return len(p), nil
+}
+
+// Bytes returns the last max bytes that were written
+func (b *tailBuffer) Bytes() []byte {
+	if over := len(b.data) - b.max; over > 0 {
+		return b.data[over:]
+	}
+	return b.data
+}
+
+// maxWarnings is how many warnings are kept from one nmap run
+const maxWarnings = 1000
+
+// stderrOutput is what was kept from nmap's stderr
+type stderrOutput struct {
+	data     []byte
+	warnings []Warning
+}
+
+// drainStderr reads stderr until EOF in a goroutine, so that nmap never blocks
+// on a full stderr pipe while stdout is being read. The kept stderr and the
+// warnings found in it are sent on the channel once stderr is closed.
+func (s Scan) drainStderr(stderr io.Reader) <-chan stderrOutput {
+	done := make(chan stderrOutput, 1)
+	max := s.configMaxStderr
+	if max <= 0 {
+		max = DefaultMaxStderr
+	}
+
+	go func() {
+		kept := &tailBuffer{max: max}
+		var warnings []Warning
+		reader := bufio.NewReader(stderr)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				kept.Write([]byte(line))
+				trimmed := strings.TrimRight(line, "\r\n")
+				if warning, ok := parseWarning(trimmed); ok && len(warnings) < maxWarnings {
+					warnings = append(warnings, warning)
+				}
+				if s.configOnStderr != nil {
+					s.configOnStderr(trimmed)
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		done <- stderrOutput{kept.Bytes(), warnings}
+	}()
+
+	return done
+}
@@ -0,0 +1,423 @@
+package nmap
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxSplitBits limits a CIDR range to being split into 1<<maxSplitBits
+// shards. Large IPv6 ranges are split into bigger shards instead of millions of
+// small ones.
+const maxSplitBits = 16
+
+// ParallelOptions configures how RunParallel splits up and runs a scan
+type ParallelOptions struct {
+	// Workers is the number of nmap processes run at the same time. When 0,
+	// the number of CPUs is used.
+	Workers int
+	// ShardSize is the most addresses scanned by one nmap process. When 0, the
+	// targets are split into 4 shards for each worker.
+	ShardSize int
+	// Retries is the number of times a failed shard is run again
+	Retries int
+}
+
+// ShardError is a shard that failed every time it was run
+type ShardError struct {
+	Hosts    []string
+	Attempts int
+	Err      error
+}
+
+// Error returns the hosts of the shard along with the last error
+func (s *ShardError) Error() string {
+	return fmt.Sprintf("Shard %s failed after %d attempts: %s", strings.Join(s.Hosts, " "), s.Attempts, s.Err)
+}
+
+// Unwrap returns the last error of the shard
+func (s *ShardError) Unwrap() error {
+	return s.Err
+}
+
+// ShardErrors is every shard that failed in RunParallel
+type ShardErrors []error
+
+// Error joins all of the errors, one per line
+func (s ShardErrors) Error() string {
+	return ConfigErrors(s).Error()
+}
+
+// Unwrap returns the list of errors so errors.Is and errors.As can look at
+// each of them
+func (s ShardErrors) Unwrap() []error {
+	return s
+}
+
+// Is returns true when one of the errors is target
+func (s ShardErrors) Is(target error) bool {
+	return ConfigErrors(s).Is(target)
+}
+
+// As finds the first error that matches target
+func (s ShardErrors) As(target interface{}) bool {
+	return ConfigErrors(s).As(target)
+}
+
+// shardTarget is a part of a target that is never split up, along with the
+// number of addresses in it
+type shardTarget struct {
+	host string
+	size uint64
+}
+
+// Shards splits the scan into scans of at most size addresses each. CIDR
+// ranges are split into smaller ranges, and hostnames and small ranges are
+// grouped together. Each shard has the same options as the scan. Targets read
+// from a file with `-iL` or picked with `-iR` can't be split.
+func (s Scan) Shards(size int) ([]Scan, error) {
+	if size < 1 {
+		return nil, errors.New("Shard size must be at least 1")
+	}
+	flags, _ := parseFlags(s.configOpts)
+	for _, name := range []string{"-iL", "-iR"} {
+		if hasFlag(flags, name) {
+			return nil, &FlagError{name, "cannot be used with sharded scans"}
+		}
+	}
+
+	var shards []Scan
+	var hosts []string
+	var total uint64
+	for _, target := range splitTargets(s.configHosts, uint64(size)) {
+		if len(hosts) != 0 && total+target.size > uint64(size) {
+			shards = append(shards, s.SetHosts(hosts...))
+			hosts, total = nil, 0
+		}
+		hosts = append(hosts, target.host)
+		total += target.size
+	}
+	if len(hosts) != 0 {
+		shards = append(shards, s.SetHosts(hosts...))
+	}
+	return shards, nil
+}
+
+// RunParallel runs the scan as several nmap processes at once. The targets are
+// split with Shards, the shards are run by a pool of workers, and the results
+// are merged into one Scan. Stats are the totals of every shard.
+//
+// Shards that fail are run again up to opts.Retries times. If some shards still
+// fail, the results of the rest are returned along with a ShardErrors. When
+// Record is used, each shard is saved to its own archive with the shard number
+// added to the path, E.x. `scan.tgz` becomes `scan-1.tgz`. When Checkpoint is
+// used, every shard saves its hosts to the same checkpoint file.
+func (s Scan) RunParallel(opts ParallelOptions) (Scan, error) {
+	args, err := s.CreateNmapArgs()
+	if err != nil {
+		return s, err
+	}
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	size := opts.ShardSize
+	if size < 1 {
+		size = defaultShardSize(s.configHosts, workers*4)
+	}
+	shards, err := s.Shards(size)
+	if err != nil {
+		return s, err
+	}
+
+	// OnStderr and OnProgress are called by every worker
+	serializeCallbacks(shards)
+	if s.configRecordPath != "" {
+		for i := range shards {
+			shards[i].configRecordPath = suffixRecordPath(s.configRecordPath, strconv.Itoa(i+1))
+		}
+	}
+
+	results := make([]Scan, len(shards))
+	errs := make([]error, len(shards))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = shards[i].runShard(opts.Retries)
+			}
+		}()
+	}
+	for i := range shards {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var failed ShardErrors
+	var done []Scan
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		} else {
+			done = append(done, results[i])
+		}
+	}
+
+	output := mergeShards(s, done)
+	output.DisplayArgs = "nmap " + strings.Join(args, " ")
+	if len(failed) != 0 {
+		return output, failed
+	}
+
+	// Every shard adds its hosts to the same checkpoint, so it is only removed
+	// once all of them finish
+	if s.configCheckpoint != "" {
+		if err := os.Remove(s.configCheckpoint); err != nil {
+			return output, err
+		}
+	}
+	return output, nil
+}
+
+// runShard runs one shard, running it again when it fails for a reason that
+// might not happen the next time
+func (s Scan) runShard(retries int) (Scan, error) {
+	var err error
+	attempts := 0
+	for attempts <= retries {
+		attempts++
+		var output Scan
+		if output, err = s.run(); err == nil {
+			return output, nil
+		}
+		if !retryable(err) {
+			break
+		}
+	}
+	return s, &ShardError{s.configHosts, attempts, err}
+}
+
+// retryable returns false for errors that will happen every time nmap is run
+func retryable(err error) bool {
+	var configErrs ConfigErrors
+	var privErr *PrivilegeError
+	var argErr *InvalidArgumentError
+	return !errors.As(err, &configErrs) &&
+		!errors.As(err, &privErr) &&
+		!errors.As(err, &argErr) &&
+		!errors.Is(err, ErrNmapNotFound)
+}
+
+// mergeShards combines the results of each shard into one Scan
+func mergeShards(s Scan, shards []Scan) Scan {
+	output := s
+	output.Hosts = make(map[string]Host)
+	output.Warnings = nil
+	output.Stats = RunStats{}
+
+	for _, shard := range shards {
+		if output.NmapVersion == "" {
+			output.NmapVersion = shard.NmapVersion
+		}
+		for address, host := range shard.Hosts {
+			if _, ok := output.Hosts[address]; !ok {
+				output.Hosts[address] = host
+			}
+		}
+		output.Warnings = append(output.Warnings, shard.Warnings...)
+
+		stats := shard.Stats
+		if output.Stats.Start.IsZero() || (!stats.Start.IsZero() && stats.Start.Before(output.Stats.Start)) {
+			output.Stats.Start = stats.Start
+		}
+		if stats.End.After(output.Stats.End) {
+			output.Stats.End = stats.End
+		}
+		if stats.Elapsed > output.Stats.Elapsed {
+			output.Stats.Elapsed = stats.Elapsed
+		}
+		output.Stats.HostsUp += stats.HostsUp
+		output.Stats.HostsDown += stats.HostsDown
+		output.Stats.HostsTotal += stats.HostsTotal
+	}
+
+	// The shards ran at the same time, so the scan took from the first start to
+	// the last finish
+	if !output.Stats.Start.IsZero() && output.Stats.End.After(output.Stats.Start) {
+		output.Stats.Elapsed = output.Stats.End.Sub(output.Stats.Start)
+	}
+	output.Stats.Summary = fmt.Sprintf("%d IP addresses (%d hosts up) scanned in %.2f seconds by %d shards",
+		output.Stats.HostsTotal, output.Stats.HostsUp, output.Stats.Elapsed.Seconds(), len(shards))
+	return output
+}
+
+// defaultShardSize splits the targets into about count shards
+func defaultShardSize(hosts []string, count int) int {
+	var total uint64
+	for _, host := range hosts {
+		total += targetSize(host)
+	}
+	size := (total + uint64(count) - 1) / uint64(count)
+	if size < 1 {
+		return 1
+	}
+	if size > 1<<30 {
+		return 1 << 30
+	}
+	return int(size)
+}
+
+// serializeCallbacks makes the OnStderr and OnProgress callbacks of scans that
+// run at the same time be called one at a time. The scans must share the same
+// callbacks. Progress.HostsCompleted counts the hosts finished by every scan,
+// so it only goes up.
+func serializeCallbacks(scans []Scan) {
+	if len(scans) == 0 {
+		return
+	}
+	if onStderr := scans[0].configOnStderr; onStderr != nil {
+		var mu sync.Mutex
+		for i := range scans {
+			scans[i].configOnStderr = func(line string) {
+				mu.Lock()
+				defer mu.Unlock()
+				onStderr(line)
+			}
+		}
+	}
+	if onProgress := scans[0].configOnProgress; onProgress != nil {
+		var mu sync.Mutex
+		// completed is the most hosts each scan has finished. A scan that is
+		// run again starts counting from 0, so only hosts past its most are
+		// added to the total.
+		completed := make([]int, len(scans))
+		total := 0
+		for i := range scans {
+			i := i
+			scans[i].configOnProgress = func(progress Progress) {
+				mu.Lock()
+				defer mu.Unlock()
+				if progress.HostsCompleted > completed[i] {
+					total += progress.HostsCompleted - completed[i]
+					completed[i] = progress.HostsCompleted
+				}
+				progress.HostsCompleted = total
+				onProgress(progress)
+			}
+		}
+	}
+}
+
+// suffixRecordPath adds a suffix to a Record path, E.x. `scan.tgz` becomes
+// `scan-1.tgz`
+func suffixRecordPath(path string, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + suffix + ext
+}
+
+// splitTargets splits the CIDR ranges in hosts into ranges of at most size
+// addresses. Other targets are kept whole.
+func splitTargets(hosts []string, size uint64) (targets []shardTarget) {
+	for _, host := range hosts {
+		_, network, err := net.ParseCIDR(host)
+		if err != nil {
+			targets = append(targets, shardTarget{host, targetSize(host)})
+			continue
+		}
+
+		ones, bits := network.Mask.Size()
+		prefix := ones
+		for prefix < bits && cidrSize(bits-prefix) > size && prefix-ones < maxSplitBits {
+			prefix++
+		}
+		if prefix == ones {
+			targets = append(targets, shardTarget{host, cidrSize(bits - ones)})
+			continue
+		}
+
+		base := new(big.Int).SetBytes(network.IP)
+		step := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix))
+		for i := 0; i < 1<<uint(prefix-ones); i++ {
+			ip := make(net.IP, len(network.IP))
+			subnet := new(big.Int).Add(base, new(big.Int).Mul(step, big.NewInt(int64(i))))
+			subnet.FillBytes(ip)
+			targets = append(targets, shardTarget{ip.String() + "/" + strconv.Itoa(prefix), cidrSize(bits - prefix)})
+		}
+	}
+	return
+}
+
+// cidrSize is the number of addresses in a CIDR range with hostBits host bits.
+// Huge IPv6 ranges are capped so that sizes can be added together.
+func cidrSize(hostBits int) uint64 {
+	if hostBits > 48 {
+		hostBits = 48
+	}
+	return 1 << uint(hostBits)
+}
+
+// targetSize estimates the number of addresses in a target. nmap's IPv4
+// octet ranges, E.x. `10.0.1-3.*`, are counted. Hostnames are one address.
+func targetSize(host string) uint64 {
+	if _, network, err := net.ParseCIDR(host); err == nil {
+		ones, bits := network.Mask.Size()
+		return cidrSize(bits - ones)
+	}
+
+	octets := strings.Split(host, ".")
+	if len(octets) != 4 {
+		return 1
+	}
+	var size uint64 = 1
+	for _, octet := range octets {
+		count := octetCount(octet)
+		if count == 0 {
+			return 1
+		}
+		size *= count
+	}
+	return size
+}
+
+// octetCount returns the number of values in an nmap octet range, or 0 when
+// the octet isn't a range
+func octetCount(octet string) (count uint64) {
+	for _, item := range strings.Split(octet, ",") {
+		if item == "*" {
+			count += 256
+			continue
+		}
+		low, high := item, item
+		if i := strings.Index(item, "-"); i != -1 {
+			low, high = item[:i], item[i+1:]
+			if low == "" {
+				low = "0"
+			}
+			if high == "" {
+				high = "255"
+			}
+		}
+		l, err := strconv.ParseUint(low, 10, 8)
+		if err != nil {
+			return 0
+		}
+		h, err := strconv.ParseUint(high, 10, 8)
+		if err != nil || h < l {
+			return 0
+		}
+		count += h - l + 1
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package nmap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// hostsRunner reports every scanned host as up. The first run of each of the
+// hosts in fail exits with an error.
+type hostsRunner struct {
+	mu    sync.Mutex
+	fail  map[string]bool
+	calls int
+}
+
+func (r *hostsRunner) Start(args []string) (Process, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+
+	stdout := `<nmaprun version="7.94" start="1700000000">`
+	for _, arg := range args {
+		if r.fail[arg] {
+			delete(r.fail, arg)
+			return &replayProcess{strings.NewReader(""), strings.NewReader("QUITTING!\n"), 1}, nil
+		}
+		if !strings.HasPrefix(arg, "-") && strings.Count(arg, ".") == 3 {
+			stdout += fmt.Sprintf(`<host><status state="up"/><address addr="%s" addrtype="ipv4"/></host>`, arg)
+		}
+	}
+	stdout += `<runstats><finished time="1700000010" elapsed="10.00"/><hosts up="1" down="0" total="1"/></runstats></nmaprun>`
+	return &replayProcess{bytes.NewReader([]byte(stdout)), strings.NewReader(""), 0}, nil
+}
+
+func (r *hostsRunner) Privileges() Privileges {
+	return Privileges{}
+}
+
+func TestScan_Shards(t *testing.T) {
+	shards, err := Init().AddHosts("10.0.0.0/23", "scanme.nmap.org", "10.1.0.1-4").Shards(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, shard := range shards {
+		got = append(got, strings.Join(shard.configHosts, " "))
+	}
+	expected := []string{"10.0.0.0/24", "10.0.1.0/24", "scanme.nmap.org 10.1.0.1-4"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected shards %q, got %q", expected, got)
+	}
+
+	if _, err := Init().AddFlags("-iL", "targets.txt").Shards(10); err == nil {
+		t.Error("Targets from a file should not be sharded")
+	}
+}
+
+func TestScan_RunParallel(t *testing.T) {
+	runner := &hostsRunner{fail: map[string]bool{"10.0.0.2": true}}
+	scan := Init().
+		AddHosts("10.0.0.1", "10.0.0.2", "10.0.0.3").
+		AddPorts(22).
+		SetRunner(runner)
+
+	output, err := scan.RunParallel(ParallelOptions{Workers: 2, ShardSize: 1, Retries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output.Hosts) != 3 || runner.calls != 4 {
+		t.Errorf("Expected 3 hosts from 4 runs, got %d hosts from %d runs", len(output.Hosts), runner.calls)
+	}
+	if output.Stats.HostsUp != 3 || output.Stats.Elapsed.Seconds() != 10 {
+		t.Errorf("Stats were not combined: %+v", output.Stats)
+	}
+
+	runner = &hostsRunner{fail: map[string]bool{"10.0.0.2": true}}
+	output, err = scan.SetRunner(runner).RunParallel(ParallelOptions{Workers: 2, ShardSize: 1})
+	if _, ok := err.(ShardErrors); !ok || len(output.Hosts) != 2 {
+		t.Errorf("Expected the failed shard to be reported with the other results, got %v and %d hosts", err, len(output.Hosts))
+	}
+}
+
+func TestScan_RunParallel_sharedFlags(t *testing.T) {
+	// Three flags added one at a time leave spare capacity in the slice that
+	// every shard shares
+	scan := Init().AddFlags("-T4").AddFlags("-sV").AddFlags("--open").
+		AddHosts("10.0.0.0/29").AddPorts(22).AddUDPPorts(53).
+		SetRunner(&hostsRunner{}).SetPrivileges(Privileges{Root: true})
+	if cap(scan.configOpts) == len(scan.configOpts) {
+		t.Fatal("Expected the flags to have spare capacity")
+	}
+
+	output, err := scan.RunParallel(ParallelOptions{Workers: 8, ShardSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output.Hosts) != 8 {
+		t.Errorf("Expected every shard to run, got %d hosts", len(output.Hosts))
+	}
+	if len(scan.configOpts) != 3 {
+		t.Errorf("Expected the scan's flags to be unchanged, got %q", scan.configOpts)
+	}
+}
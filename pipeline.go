@@ -0,0 +1,163 @@
+package nmap
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Stage is one scan in a pipeline
+type Stage struct {
+	// Name describes the stage in errors, E.x. `discovery` or `versions`
+	Name string
+	// Scan has the options of the stage. The first stage is run as it is.
+	// Later stages have their hosts and ports replaced by the ports found in
+	// the stage before them.
+	Scan Scan
+	// Select picks the ports from the stage before that this stage scans. When
+	// nil, open ports are picked.
+	Select func(Port) bool
+}
+
+// StageResult is the output of one stage of a pipeline
+type StageResult struct {
+	Name string
+	Scan Scan
+}
+
+// PipelineResult is the output of every stage of a pipeline, along with the
+// results of all of the stages merged together
+type PipelineResult struct {
+	Stages []StageResult
+	Scan   Scan
+}
+
+// StageError is returned by RunPipeline when a stage fails
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+// Error returns the stage along with its error
+func (s *StageError) Error() string {
+	return "Stage '" + s.Stage + "' failed: " + s.Err.Error()
+}
+
+// Unwrap returns the stage's error
+func (s *StageError) Unwrap() error {
+	return s.Err
+}
+
+// RunPipeline runs stages one after another, where each stage only scans the
+// ports found by the stage before it on each host. This is usually a fast port
+// sweep followed by a slower scan of the open ports:
+//
+//	result, err := nmap.RunPipeline(
+//		nmap.Stage{Name: "sweep", Scan: nmap.Init().AddHosts("10.0.0.0/24").AddPortRange(1, 65535)},
+//		nmap.Stage{Name: "versions", Scan: nmap.Init().AddFlags("-sV", "-sC")},
+//	)
+//
+// Hosts with the same ports are scanned by one nmap process, and hosts without
+// any selected ports are not scanned again. Later stages are run with `-Pn`,
+// since the hosts are known to be up. The results of every stage are merged
+// into one Scan with MergeScans, so the last stage's results win.
+//
+// When a stage fails, the results of the stages before it are returned along
+// with a StageError.
+func RunPipeline(stages ...Stage) (*PipelineResult, error) {
+	result := &PipelineResult{Scan: Init()}
+	var scans []Scan
+
+	for i, stage := range stages {
+		var output Scan
+		var err error
+		if i == 0 {
+			output, err = stage.Scan.Run()
+		} else {
+			output, err = stage.runOn(result.Stages[i-1].Scan)
+		}
+		if err != nil {
+			result.Scan = MergeScans(scans...)
+			return result, &StageError{stage.Name, err}
+		}
+
+		result.Stages = append(result.Stages, StageResult{stage.Name, output})
+		scans = append(scans, output)
+	}
+
+	result.Scan = MergeScans(scans...)
+	return result, nil
+}
+
+// runOn runs the stage on the selected ports of the previous stage's hosts
+func (stage Stage) runOn(previous Scan) (Scan, error) {
+	selectPort := stage.Select
+	if selectPort == nil {
+		selectPort = func(p Port) bool { return isOpen(p.State) }
+	}
+
+	// Hosts with the same ports are grouped into one scan
+	type portGroup struct {
+		tcp   []uint16
+		udp   []uint16
+		hosts []string
+	}
+	groups := make(map[string]*portGroup)
+	var order []string
+	for _, address := range sortedAddresses(previous.Hosts) {
+		var tcp, udp []uint16
+		var keys []PortKey
+		for _, port := range previous.Hosts[address].Ports {
+			if !selectPort(port) {
+				continue
+			}
+			switch port.Protocol {
+			case "tcp":
+				tcp = append(tcp, uint16(port.ID))
+			case "udp":
+				udp = append(udp, uint16(port.ID))
+			default:
+				continue
+			}
+			keys = append(keys, port.key())
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return portKeyLess(keys[i], keys[j]) })
+		var id []string
+		for _, key := range keys {
+			id = append(id, key.Protocol+":"+strconv.Itoa(int(key.ID)))
+		}
+		groupID := strings.Join(id, ",")
+		if groups[groupID] == nil {
+			groups[groupID] = &portGroup{tcp: tcp, udp: udp}
+			order = append(order, groupID)
+		}
+		groups[groupID].hosts = append(groups[groupID].hosts, address)
+	}
+
+	base := stage.Scan
+	flags, _ := parseFlags(base.configOpts)
+	if !hasFlag(flags, "-Pn") {
+		base = base.AddFlags("-Pn")
+	}
+
+	var outputs []Scan
+	for _, groupID := range order {
+		group := groups[groupID]
+		scan := base.
+			SetHosts(group.hosts...).
+			SetPorts().
+			SetTCPPorts(group.tcp...).
+			SetUDPPorts(group.udp...)
+		output, err := scan.Run()
+		if err != nil {
+			return output, err
+		}
+		outputs = append(outputs, output)
+	}
+
+	return MergeScans(outputs...), nil
+}
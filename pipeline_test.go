@@ -0,0 +1,95 @@
+package nmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func pipelineHost(address string, ports string) string {
+	return `<host><status state="up" reason="syn-ack"/>
+<address addr="` + address + `" addrtype="ipv4"/>
+<ports>` + ports + `</ports>
+</host>
+`
+}
+
+func pipelinePort(id, state, product string) string {
+	return `<port protocol="tcp" portid="` + id + `"><state state="` + state + `" reason="syn-ack"/><service name="ssh" product="` + product + `" method="probed"/></port>`
+}
+
+func TestRunPipeline(t *testing.T) {
+	sweep := funcRunner(func(args []string) (string, int) {
+		return `<nmaprun start="1700000000">` +
+			pipelineHost("10.0.0.1", pipelinePort("22", "open", "")+pipelinePort("80", "open", "")) +
+			pipelineHost("10.0.0.2", pipelinePort("22", "open", "")+pipelinePort("23", "closed", "")) +
+			pipelineHost("10.0.0.3", pipelinePort("22", "open", "")) +
+			pipelineHost("10.0.0.4", pipelinePort("23", "closed", "")) +
+			"</nmaprun>", 0
+	})
+
+	var calls []string
+	versions := funcRunner(func(args []string) (string, int) {
+		calls = append(calls, strings.Join(args, " "))
+		output := `<nmaprun start="1700000100">`
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "10.0.0.") {
+				output += pipelineHost(arg, pipelinePort("22", "open", "OpenSSH"))
+			}
+		}
+		return output + "</nmaprun>", 0
+	})
+
+	result, err := RunPipeline(
+		Stage{Name: "sweep", Scan: Init().AddHosts("10.0.0.0/29").AddPorts(22, 23, 80).SetRunner(sweep)},
+		Stage{Name: "versions", Scan: Init().AddFlags("-sV").SetRunner(versions)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("Expected hosts with the same ports to be scanned together, got %q", calls)
+	}
+	if !strings.HasSuffix(calls[0], "-pT:22,80 10.0.0.1") || !strings.Contains(calls[0], "-Pn") {
+		t.Errorf("Expected the first group to scan its open ports, got %q", calls[0])
+	}
+	if !strings.HasSuffix(calls[1], "-pT:22 10.0.0.2 10.0.0.3") {
+		t.Errorf("Expected the second group to scan its open ports, got %q", calls[1])
+	}
+
+	if len(result.Stages) != 2 || result.Stages[1].Name != "versions" {
+		t.Fatalf("Expected a result for each stage, got %v", result.Stages)
+	}
+	if _, ok := result.Stages[1].Scan.Hosts["10.0.0.4"]; ok {
+		t.Error("Expected a host without open ports to be skipped")
+	}
+	host := result.Scan.Hosts["10.0.0.1"]
+	if len(host.Ports) != 2 || host.Ports[0].Product != "OpenSSH" || host.Ports[1].ID != 80 {
+		t.Errorf("Expected the stages to be merged, got %v", host.Ports)
+	}
+	if len(result.Scan.Hosts) != 4 {
+		t.Errorf("Expected every host in the merged scan, got %d", len(result.Scan.Hosts))
+	}
+}
+
+func TestRunPipeline_stageError(t *testing.T) {
+	sweep := funcRunner(func(args []string) (string, int) {
+		return "<nmaprun>" + pipelineHost("10.0.0.1", pipelinePort("22", "open", "")) + "</nmaprun>", 0
+	})
+	failed := funcRunner(func(args []string) (string, int) {
+		return "", 1
+	})
+
+	result, err := RunPipeline(
+		Stage{Name: "sweep", Scan: Init().AddHosts("10.0.0.1").AddPorts(22).SetRunner(sweep)},
+		Stage{Name: "versions", Scan: Init().AddFlags("-sV").SetRunner(failed)},
+	)
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != "versions" {
+		t.Fatalf("Expected a StageError for the second stage, got %v", err)
+	}
+	if _, ok := result.Scan.Hosts["10.0.0.1"]; !ok || len(result.Stages) != 1 {
+		t.Error("Expected the results of the first stage to be kept")
+	}
+}
@@ -13,7 +13,41 @@ type Port struct {
 	State   string
 	Service string
 	Method  string
-	Scripts []Script
+	// Product, Version, ExtraInfo and CPEs are found by version detection
+	// (`-sV`), E.x. `OpenSSH`, `6.6.1p1 Ubuntu 2ubuntu2.13`, `protocol 2.0` and
+	// `cpe:/a:openbsd:openssh:6.6.1p1`
+	Product   string
+	Version   string
+	ExtraInfo string
+	CPEs      []string
+	Scripts   []Script
+	// Observations are the scans that saw the port. They are only set on
+	// ports from MergeScans.
+	Observations []Observation
+}
+
+// PortKey identifies a port on a host
+type PortKey struct {
+	Protocol string
+	ID       uint32
+}
+
+// key returns the protocol and port number of the port
+func (p Port) key() PortKey {
+	return PortKey{p.Protocol, p.ID}
+}
+
+// portKeyLess sorts ports by protocol and then number
+func portKeyLess(a, b PortKey) bool {
+	if a.Protocol != b.Protocol {
+		return a.Protocol < b.Protocol
+	}
+	return a.ID < b.ID
+}
+
+// isOpen returns true for the `open` and `open|filtered` port states
+func isOpen(state string) bool {
+	return strings.HasPrefix(state, "open")
 }
 
 // Script are used for gathering nmap NSE script information
@@ -31,12 +65,16 @@ type Element struct {
 
 func (port rawPort) cleanPort() Port {
 	output := Port{
-		port.Protocol,
-		port.Port,
-		port.State.State,
-		port.Service.Name,
-		port.Service.Method,
-		[]Script{},
+		Protocol:  port.Protocol,
+		ID:        port.Port,
+		State:     port.State.State,
+		Service:   port.Service.Name,
+		Method:    port.Service.Method,
+		Product:   port.Service.Product,
+		Version:   port.Service.Version,
+		ExtraInfo: port.Service.ExtraInfo,
+		CPEs:      port.Service.CPEs,
+		Scripts:   []Script{},
 	}
 	for _, script := range port.Scripts {
 		s := Script{script.Name, script.Output, []Element{}}
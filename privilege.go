@@ -0,0 +1,104 @@
+package nmap
+
+import (
+	"os/exec"
+)
+
+// Privileges describes the raw network access that nmap has. Without it, nmap
+// can only do TCP connect scans.
+type Privileges struct {
+	// Root is set when running as the root user
+	Root bool
+	// NetRaw and NetAdmin are set when the process or the nmap binary have the
+	// Linux CAP_NET_RAW and CAP_NET_ADMIN capabilities
+	NetRaw   bool
+	NetAdmin bool
+}
+
+// Privileged returns true when nmap can send raw packets. This is required for
+// SYN, UDP and OS scans amongst others.
+func (p Privileges) Privileged() bool {
+	return p.Root || (p.NetRaw && p.NetAdmin)
+}
+
+// DetectPrivileges finds the privileges that nmap will have when it is run by
+// this process. On Linux, both the capabilities of this process and the file
+// capabilities of the nmap binary are checked.
+func DetectPrivileges() Privileges {
+	nmapPath, _ := exec.LookPath("nmap")
+	return detectPrivileges(nmapPath)
+}
+
+// SetPrivileges overrides the detected privileges. This is needed when nmap is
+// not run directly by this process, such as when it is run with sudo.
+func (s Scan) SetPrivileges(privileges Privileges) Scan {
+	s.configPrivileges = &privileges
+	return s
+}
+
+// Privileges returns the privileges that nmap will be run with. These are the
+// privileges given to SetPrivileges, otherwise they are detected by the Runner
+// if it is a PrivilegeDetector.
+func (s Scan) Privileges() Privileges {
+	if s.configPrivileges != nil {
+		return *s.configPrivileges
+	}
+	if detector, ok := s.runner().(PrivilegeDetector); ok {
+		return detector.Privileges()
+	}
+	return Privileges{}
+}
+
+// privileged returns whether nmap will be able to send raw packets. Flags
+// given by the user for the privilege mode win over the detected privileges.
+func (s Scan) privileged(flags []parsedFlag) bool {
+	if hasFlag(flags, "--privileged") {
+		return true
+	}
+	if hasFlag(flags, "--unprivileged") {
+		return false
+	}
+	return s.Privileges().Privileged()
+}
+
+// checkPrivileges returns a PrivilegeError when the scan uses features that
+// need privileges nmap won't have
+func (s Scan) checkPrivileges(flags []parsedFlag) error {
+	if s.privileged(flags) {
+		return nil
+	}
+
+	var needed []string
+	for _, f := range flags {
+		if f.option.Privileged {
+			needed = append(needed, f.flag)
+		}
+	}
+	if len(s.configUDPPorts) != 0 && !hasFlag(flags, "-sU") {
+		needed = append(needed, "-sU")
+	}
+
+	if len(needed) != 0 {
+		return &PrivilegeError{Flags: needed}
+	}
+	return nil
+}
+
+// privilegeArgs returns the default TCP scan type and the flag telling nmap
+// which privilege mode to use. Without root, nmap only uses capabilities when
+// it is given `--privileged`.
+func (s Scan) privilegeArgs(flags []parsedFlag) (tcpScan string, mode []string) {
+	if !s.privileged(flags) {
+		tcpScan = "-sT"
+		if !hasGroup(flags, groupPrivs) {
+			mode = []string{"--unprivileged"}
+		}
+		return
+	}
+
+	tcpScan = "-sS"
+	if !hasGroup(flags, groupPrivs) && !s.Privileges().Root {
+		mode = []string{"--privileged"}
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package nmap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Capability bits from linux/capability.h
+const (
+	capNetAdmin = 12
+	capNetRaw   = 13
+)
+
+// vfsCapFlagEffective is set in a file capability when the permitted
+// capabilities are also effective when the file is executed
+const vfsCapFlagEffective = 0x000001
+
+func detectPrivileges(nmapPath string) Privileges {
+	privileges := Privileges{Root: os.Geteuid() == 0}
+
+	caps := processCapabilities()
+	if nmapPath != "" {
+		caps |= fileCapabilities(nmapPath)
+	}
+	privileges.NetRaw = caps&(1<<capNetRaw) != 0
+	privileges.NetAdmin = caps&(1<<capNetAdmin) != 0
+
+	return privileges
+}
+
+// processCapabilities reads the effective capabilities of this process
+func processCapabilities() uint64 {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "CapEff:") {
+			caps, err := strconv.ParseUint(strings.TrimSpace(line[len("CapEff:"):]), 16, 64)
+			if err != nil {
+				return 0
+			}
+			return caps
+		}
+	}
+	return 0
+}
+
+// fileCapabilities reads the capabilities that a binary gets when it is run.
+// These are set with `setcap cap_net_raw,cap_net_admin+eip /path/to/nmap`
+func fileCapabilities(path string) uint64 {
+	data := make([]byte, 24)
+	n, err := syscall.Getxattr(path, "security.capability", data)
+	if err != nil || n < 12 {
+		return 0
+	}
+
+	// struct vfs_cap_data: magic_etc, then permitted and inheritable pairs
+	magic := binary.LittleEndian.Uint32(data[0:4])
+	if magic&vfsCapFlagEffective == 0 {
+		return 0
+	}
+	caps := uint64(binary.LittleEndian.Uint32(data[4:8]))
+	if n >= 20 {
+		caps |= uint64(binary.LittleEndian.Uint32(data[12:16])) << 32
+	}
+	return caps
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package nmap
+
+import (
+	"os"
+)
+
+// detectPrivileges only checks for root, since capabilities are Linux-only. On
+// Windows the process is never reported as privileged, so use SetPrivileges
+// when nmap is run by an administrator with Npcap installed.
+func detectPrivileges(nmapPath string) Privileges {
+	return Privileges{Root: os.Geteuid() == 0}
+}
@@ -0,0 +1,179 @@
+package nmap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+// DefaultStatsEvery is how often nmap reports progress when OnProgress is used
+const DefaultStatsEvery = 5 * time.Second
+
+// ProgressKind is the kind of a Progress event
+type ProgressKind string
+
+// Kinds of Progress events
+const (
+	// ProgressBegin is sent when nmap starts a phase of the scan
+	ProgressBegin ProgressKind = "begin"
+	// ProgressUpdate is sent every `--stats-every` during a phase
+	ProgressUpdate ProgressKind = "update"
+	// ProgressEnd is sent when nmap finishes a phase of the scan
+	ProgressEnd ProgressKind = "end"
+	// ProgressHost is sent when nmap finishes scanning a host
+	ProgressHost ProgressKind = "host"
+)
+
+// Progress is an update from nmap about how far along the scan is
+type Progress struct {
+	Kind ProgressKind
+	// Phase is the part of the scan nmap is running, E.x. `SYN Stealth Scan`
+	// or `Service scan`
+	Phase string
+	// Time is when nmap sent the update
+	Time time.Time
+	// Percent, Remaining and ETA are only set for ProgressUpdate events
+	Percent   float64
+	Remaining time.Duration
+	ETA       time.Time
+	// ExtraInfo is nmap's note at the end of a phase, E.x. `1000 total ports`
+	ExtraInfo string
+	// Host is the address of the host for ProgressHost events
+	Host string
+	// HostsCompleted is the number of hosts nmap has finished so far. With
+	// RunParallel and RunComposite it counts the hosts of every nmap process.
+	HostsCompleted int
+}
+
+// OnProgress sets a function that is called with progress updates while the
+// scan is running. nmap is run with `--stats-every` so that it sends updates
+// during long phases.
+func (s Scan) OnProgress(callback func(Progress)) Scan {
+	s.configOnProgress = callback
+	return s
+}
+
+// SetStatsEvery sets how often nmap sends progress updates to the OnProgress
+// callback. The default is DefaultStatsEvery.
+func (s Scan) SetStatsEvery(interval time.Duration) Scan {
+	s.configStatsEvery = interval
+	return s
+}
+
+// progressArgs returns `--stats-every` when a progress callback is set and the
+// flag wasn't added by hand
+func (s Scan) progressArgs(flags []parsedFlag) []string {
+	if s.configOnProgress == nil || hasFlag(flags, "--stats-every") {
+		return nil
+	}
+	interval := s.configStatsEvery
+	if interval <= 0 {
+		interval = DefaultStatsEvery
+	}
+	return []string{"--stats-every", formatDuration(interval)}
+}
+
+// rawTask is one of nmap's <taskbegin>, <taskprogress> or <taskend> elements
+type rawTask struct {
+	XMLName xml.Name
+
+	Task      string  `xml:"task,attr"`
+	Time      int64   `xml:"time,attr"`
+	Percent   float64 `xml:"percent,attr"`
+	Remaining int64   `xml:"remaining,attr"`
+	ETC       int64   `xml:"etc,attr"`
+	ExtraInfo string  `xml:"extrainfo,attr"`
+}
+
+// progressWriter finds progress elements in nmap's XML output as it is
+// written, and sends them to the callback. nmap writes each of them on its own
+// line.
+type progressWriter struct {
+	callback func(Progress)
+	line     []byte
+	hosts    int
+	host     string
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i == -1 {
+			break
+		}
+		w.parseLine(bytes.TrimSpace(w.line[:i]))
+		w.line = w.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// parseLine sends the progress event for one line of output, if it has one
+func (w *progressWriter) parseLine(line []byte) {
+	switch {
+	case bytes.HasPrefix(line, []byte("<task")):
+		var task rawTask
+		if err := xml.Unmarshal(line, &task); err != nil {
+			return
+		}
+		progress := Progress{
+			Phase:          task.Task,
+			Time:           unixTime(task.Time),
+			ExtraInfo:      task.ExtraInfo,
+			HostsCompleted: w.hosts,
+		}
+		switch task.XMLName.Local {
+		case "taskbegin":
+			progress.Kind = ProgressBegin
+		case "taskprogress":
+			progress.Kind = ProgressUpdate
+			progress.Percent = task.Percent
+			progress.Remaining = time.Duration(task.Remaining) * time.Second
+			progress.ETA = unixTime(task.ETC)
+		case "taskend":
+			progress.Kind = ProgressEnd
+		default:
+			return
+		}
+		w.callback(progress)
+
+	case bytes.HasPrefix(line, []byte("<address ")) && w.host == "":
+		var address rawAddress
+		if err := xml.Unmarshal(line, &address); err == nil && address.AddressType != "mac" {
+			w.host = address.Address
+		}
+
+	case bytes.HasPrefix(line, []byte("</host>")):
+		w.hosts++
+		w.callback(Progress{Kind: ProgressHost, Host: w.host, Time: time.Now().UTC(), HostsCompleted: w.hosts})
+		w.host = ""
+	}
+}
+
+// unixTime converts one of nmap's timestamps, which are 0 when unknown
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// String formats the event for printing, E.x. `SYN Stealth Scan: 42.10% done,
+// 1m12s remaining`
+func (p Progress) String() string {
+	switch p.Kind {
+	case ProgressBegin:
+		return p.Phase + ": started"
+	case ProgressUpdate:
+		return p.Phase + ": " + strconv.FormatFloat(p.Percent, 'f', 2, 64) + "% done, " + p.Remaining.String() + " remaining"
+	case ProgressEnd:
+		if p.ExtraInfo != "" {
+			return p.Phase + ": done (" + p.ExtraInfo + ")"
+		}
+		return p.Phase + ": done"
+	case ProgressHost:
+		return p.Host + ": done (" + strconv.Itoa(p.HostsCompleted) + " hosts completed)"
+	}
+	return string(p.Kind)
+}
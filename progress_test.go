@@ -0,0 +1,88 @@
+package nmap
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScan_OnProgress(t *testing.T) {
+	stdout := `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap" args="nmap -oX - --stats-every 1s 10.0.0.1" start="1700000000" version="7.94">
+<taskbegin task="SYN Stealth Scan" time="1700000001"/>
+<taskprogress task="SYN Stealth Scan" time="1700000002" percent="42.10" remaining="72" etc="1700000074"/>
+<taskend task="SYN Stealth Scan" time="1700000074" extrainfo="1000 total ports"/>
+<host starttime="1700000001" endtime="1700000074"><status state="up" reason="syn-ack"/>
+<address addr="10.0.0.1" addrtype="ipv4"/>
+<address addr="00:11:22:33:44:55" addrtype="mac"/>
+</host>
+</nmaprun>
+`
+	session := &Session{Stdout: []byte(stdout)}
+
+	var events []Progress
+	scan := Init().
+		AddHosts("10.0.0.1").
+		SetRunner(replayRunner{session}).
+		SetStatsEvery(time.Second).
+		OnProgress(func(p Progress) { events = append(events, p) })
+
+	args, _ := scan.CreateNmapArgs()
+	if !strings.Contains(strings.Join(args, " "), "--stats-every 1s") {
+		t.Errorf("--stats-every was not added: %q", args)
+	}
+
+	if _, err := scan.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 progress events, got %v", events)
+	}
+	update := events[1]
+	if update.Kind != ProgressUpdate || update.Percent != 42.10 || update.Remaining != 72*time.Second ||
+		!update.ETA.Equal(time.Unix(1700000074, 0)) {
+		t.Errorf("Progress update was not parsed: %+v", update)
+	}
+	if events[2].String() != "SYN Stealth Scan: done (1000 total ports)" {
+		t.Errorf("Unexpected end event: %s", events[2])
+	}
+	if events[3].Kind != ProgressHost || events[3].Host != "10.0.0.1" || events[3].HostsCompleted != 1 {
+		t.Errorf("Host completion was not sent: %+v", events[3])
+	}
+}
+
+func TestScan_RunParallel_progress(t *testing.T) {
+	// Each shard finishes two hosts, each on its own line like nmap writes them
+	runner := funcRunner(func(args []string) (string, int) {
+		target := args[len(args)-1]
+		stdout := "<nmaprun>\n"
+		for _, suffix := range []string{"a", "b"} {
+			stdout += `<host><status state="up"/>` + "\n" +
+				`<address addr="` + target + suffix + `" addrtype="ipv4"/>` + "\n</host>\n"
+		}
+		return stdout + "</nmaprun>\n", 0
+	})
+
+	var completed []int
+	_, err := Init().
+		AddHosts("10.0.0.0/29").
+		SetRunner(runner).
+		OnProgress(func(p Progress) {
+			if p.Kind == ProgressHost {
+				completed = append(completed, p.HostsCompleted)
+			}
+		}).
+		RunParallel(ParallelOptions{Workers: 4, ShardSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(completed) != 8 {
+		t.Fatalf("Expected a host event for each host, got %v", completed)
+	}
+	for i, count := range completed {
+		if count != i+1 {
+			t.Fatalf("Expected the completed hosts to count up across shards, got %v", completed)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package nmap
+
+import "errors"
+
+// RescanOptions restricts what Scan.Rescan scans again. The zero value
+// rescans everything the scan was run with.
+type RescanOptions struct {
+	// UpOnly only rescans the hosts that were up
+	UpOnly bool
+	// Ports, TCPPorts and UDPPorts replace the ports of the scan when any of
+	// them are set. They work the same as AddPorts, AddTCPPorts and
+	// AddUDPPorts.
+	Ports    []uint16
+	TCPPorts []uint16
+	UDPPorts []uint16
+}
+
+// Rescan runs the scan again with the same options and returns the new Scan
+// along with what changed since the scan was run. It is used on the Scan
+// returned by Run. The rescan isn't recorded, so the archive of a scan run
+// with Record is never overwritten.
+//
+// When the rescan is restricted to hosts that were up or to some ports, the
+// diff only compares those hosts and ports, so hosts and ports that weren't
+// scanned again aren't reported as removed.
+func (s Scan) Rescan(opts RescanOptions) (Scan, ScanDiff, error) {
+	scan := *s.scanConfig()
+	previous := s
+
+	if opts.UpOnly {
+		var hosts []string
+		for _, address := range sortedAddresses(s.Hosts) {
+			if s.Hosts[address].State == "up" {
+				hosts = append(hosts, address)
+			}
+		}
+		if len(hosts) == 0 {
+			return s, ScanDiff{}, errors.New("No hosts were up to rescan")
+		}
+		scan = scan.SetHosts(hosts...)
+		previous = previous.FilterHosts(func(h Host) bool { return h.State == "up" })
+	}
+
+	if len(opts.Ports) != 0 || len(opts.TCPPorts) != 0 || len(opts.UDPPorts) != 0 {
+		scan = scan.
+			SetPorts(opts.Ports...).
+			SetTCPPorts(opts.TCPPorts...).
+			SetUDPPorts(opts.UDPPorts...)
+		previous = previous.withPorts(opts)
+	}
+
+	output, err := scan.Run()
+	if err != nil {
+		return output, ScanDiff{}, err
+	}
+	return output, DiffScans(previous, output), nil
+}
+
+// withPorts returns a new Scan where hosts only have the ports in opts. Hosts
+// without any of the ports are kept.
+func (s Scan) withPorts(opts RescanOptions) Scan {
+	ports := make(map[PortKey]bool)
+	for _, id := range opts.Ports {
+		ports[PortKey{"tcp", uint32(id)}] = true
+		ports[PortKey{"udp", uint32(id)}] = true
+	}
+	for _, id := range opts.TCPPorts {
+		ports[PortKey{"tcp", uint32(id)}] = true
+	}
+	for _, id := range opts.UDPPorts {
+		ports[PortKey{"udp", uint32(id)}] = true
+	}
+
+	output := s
+	output.Hosts = make(map[string]Host, len(s.Hosts))
+	for address, host := range s.Hosts {
+		var kept []Port
+		for _, port := range host.Ports {
+			if ports[port.key()] {
+				kept = append(kept, port)
+			}
+		}
+		host.Ports = kept
+		output.Hosts[address] = host
+	}
+	return output
+}
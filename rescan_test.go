@@ -0,0 +1,120 @@
+package nmap
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func rescanRunner(calls *[]string, ports map[string]string) funcRunner {
+	return funcRunner(func(args []string) (string, int) {
+		*calls = append(*calls, strings.Join(args, " "))
+		output := "<nmaprun>"
+		for _, arg := range args {
+			if p, ok := ports[arg]; ok {
+				output += `<host><status state="up"/><address addr="` + arg + `" addrtype="ipv4"/><ports>` + p + `</ports></host>`
+			}
+		}
+		return output + "</nmaprun>", 0
+	})
+}
+
+func TestScan_Rescan(t *testing.T) {
+	ssh := `<port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>`
+	http := `<port protocol="tcp" portid="80"><state state="open"/><service name="http"/></port>`
+
+	var calls []string
+	first, err := Init().AddHosts("10.0.0.1", "10.0.0.2").AddPorts(22, 80).AddFlags("-T4").
+		SetRunner(rescanRunner(&calls, map[string]string{"10.0.0.1": ssh + http})).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, diff, err := first.
+		SetRunner(rescanRunner(&calls, map[string]string{"10.0.0.1": ssh, "10.0.0.2": ssh})).
+		Rescan(RescanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls[1] != calls[0] {
+		t.Errorf("Expected the same arguments, got %q and %q", calls[0], calls[1])
+	}
+	if len(second.Hosts) != 2 {
+		t.Errorf("Expected the new scan, got %d hosts", len(second.Hosts))
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Address != "10.0.0.2" {
+		t.Errorf("Expected 10.0.0.2 to be added, got %v", diff.Added)
+	}
+	if len(diff.Changed) != 1 || len(diff.Changed[0].Ports) != 1 || diff.Changed[0].Ports[0].ID != 80 {
+		t.Errorf("Expected port 80 to be removed, got %v", diff.Changed)
+	}
+}
+
+func TestScan_Rescan_restricted(t *testing.T) {
+	ssh := `<port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>`
+	http := `<port protocol="tcp" portid="80"><state state="open"/><service name="http"/></port>`
+
+	var calls []string
+	first, err := Init().AddHosts("10.0.0.1", "10.0.0.2").AddPorts(22, 80).
+		SetRunner(rescanRunner(&calls, map[string]string{"10.0.0.1": ssh + http})).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, diff, err := first.
+		SetRunner(rescanRunner(&calls, map[string]string{"10.0.0.1": ssh})).
+		Rescan(RescanOptions{UpOnly: true, TCPPorts: []uint16{22}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(calls[1], "-pT:22 10.0.0.1") {
+		t.Errorf("Expected only the up host and port 22 to be rescanned, got %q", calls[1])
+	}
+	if !diff.Empty() {
+		t.Errorf("Expected ports that weren't rescanned to be left out of the diff, got %v", diff)
+	}
+}
+
+func TestScan_Rescan_noHostsUp(t *testing.T) {
+	if _, _, err := Init().AddHosts("10.0.0.1").Rescan(RescanOptions{UpOnly: true}); err == nil {
+		t.Error("Expected an error when no hosts were up")
+	}
+}
+
+func TestScan_Rescan_record(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rescan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "scan.tgz")
+
+	ssh := `<port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>`
+	var calls []string
+	first, err := Init().AddHosts("10.0.0.1").AddPorts(22).Record(archive).
+		SetRunner(rescanRunner(&calls, map[string]string{"10.0.0.1": ssh})).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := first.Rescan(RescanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Hosts["10.0.0.1"].Rescan().Run(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || !bytes.Equal(recorded, after) {
+		t.Error("Expected rescans to leave the recorded archive alone")
+	}
+}
@@ -0,0 +1,204 @@
+package nmap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Runner starts nmap. The arguments are the ones made by CreateNmapArgs, and
+// don't include the nmap binary itself. A Runner can run nmap locally, through
+// sudo, inside of a container or on another host.
+type Runner interface {
+	Start(args []string) (Process, error)
+}
+
+// Process is an nmap process started by a Runner
+type Process interface {
+	// Stdout and Stderr are the output streams of nmap. Both must be read
+	// until EOF before calling Wait.
+	Stdout() io.Reader
+	Stderr() io.Reader
+	// Wait waits for nmap to exit and returns its exit code. The error is only
+	// set when the exit code couldn't be found.
+	Wait() (exitCode int, err error)
+}
+
+// PrivilegeDetector is implemented by Runners that know the privileges nmap
+// will be run with. Scans use it to find their privileges when SetPrivileges
+// isn't used.
+type PrivilegeDetector interface {
+	Privileges() Privileges
+}
+
+// ExecRunner runs nmap as a child process. It is the default Runner.
+type ExecRunner struct {
+	// Path is the nmap binary. When empty, nmap is looked up in the PATH, or
+	// `nmap` is used when there is a Prefix.
+	Path string
+	// Prefix is a command that nmap is run through, E.x. `sudo -n`
+	Prefix []string
+	// QuoteArgs quotes the nmap command for a POSIX shell. This is needed when
+	// the Prefix passes the command to a shell, like ssh does.
+	QuoteArgs bool
+	// Env is the environment of the process. When nil, the environment of this
+	// process is used.
+	Env []string
+	// Dir is the working directory of the process
+	Dir string
+}
+
+// SudoRunner runs nmap as root using sudo. sudo must be able to run nmap
+// without asking for a password.
+func SudoRunner() ExecRunner {
+	return ExecRunner{Prefix: []string{"sudo", "-n"}}
+}
+
+// DockerRunner runs nmap inside of a running container using `docker exec`
+func DockerRunner(container string) ExecRunner {
+	return ExecRunner{Prefix: []string{"docker", "exec", container}}
+}
+
+// SSHRunner runs nmap on another host over ssh. sshArgs are given to ssh
+// before the host, E.x. `-J jumphost` or `-i key`.
+func SSHRunner(host string, sshArgs ...string) ExecRunner {
+	prefix := append([]string{"ssh"}, sshArgs...)
+	prefix = append(prefix, host, "--")
+	return ExecRunner{Prefix: prefix, QuoteArgs: true}
+}
+
+// Command returns the command that runs nmap with the arguments
+func (r ExecRunner) Command(args []string) (*exec.Cmd, error) {
+	nmapPath := r.Path
+	if nmapPath == "" {
+		if len(r.Prefix) != 0 {
+			nmapPath = "nmap"
+		} else {
+			found, err := exec.LookPath("nmap")
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrNmapNotFound, err)
+			}
+			nmapPath = found
+		}
+	}
+
+	argv := append([]string{nmapPath}, args...)
+	if r.QuoteArgs {
+		quoted := make([]string, 0, len(argv))
+		for _, arg := range argv {
+			quoted = append(quoted, shellQuote(arg))
+		}
+		argv = []string{strings.Join(quoted, " ")}
+	}
+	argv = append(append([]string{}, r.Prefix...), argv...)
+
+	name, err := exec.LookPath(argv[0])
+	if err != nil {
+		if len(r.Prefix) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNmapNotFound, err)
+		}
+		return nil, err
+	}
+
+	cmd := exec.Command(name, argv[1:]...)
+	cmd.Env = r.Env
+	cmd.Dir = r.Dir
+	return cmd, nil
+}
+
+// Start starts nmap
+func (r ExecRunner) Start(args []string) (Process, error) {
+	cmd, err := r.Command(args)
+	if err != nil {
+		return nil, err
+	}
+
+	outPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	errPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &execProcess{cmd, outPipe, errPipe}, nil
+}
+
+// Privileges returns root when nmap is run with sudo, and the privileges of
+// this process when nmap is run directly. Otherwise the privileges are unknown,
+// so no privileges are returned.
+func (r ExecRunner) Privileges() Privileges {
+	if len(r.Prefix) != 0 {
+		if r.Prefix[0] == "sudo" {
+			return Privileges{Root: true}
+		}
+		return Privileges{}
+	}
+
+	nmapPath := r.Path
+	if nmapPath == "" {
+		nmapPath, _ = exec.LookPath("nmap")
+	}
+	return detectPrivileges(nmapPath)
+}
+
+// cacheKey identifies the nmap binary that the runner runs
+func (r ExecRunner) cacheKey() string {
+	nmapPath := r.Path
+	if nmapPath == "" && len(r.Prefix) == 0 {
+		nmapPath, _ = exec.LookPath("nmap")
+	}
+	return strings.Join(append(append([]string{}, r.Prefix...), nmapPath), "\x00")
+}
+
+// execProcess is a Process started by ExecRunner
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stderr io.Reader
+}
+
+func (p *execProcess) Stdout() io.Reader { return p.stdout }
+func (p *execProcess) Stderr() io.Reader { return p.stderr }
+
+func (p *execProcess) Wait() (int, error) {
+	err := p.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// shellQuote quotes an argument for a POSIX shell
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:,=+@%") == "" {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// SetRunner sets how nmap is run. By default, nmap is run locally with
+// ExecRunner.
+func (s Scan) SetRunner(runner Runner) Scan {
+	s.configRunner = runner
+	return s
+}
+
+// runner returns the Runner used to run nmap
+func (s Scan) runner() Runner {
+	if s.configRunner != nil {
+		return s.configRunner
+	}
+	return ExecRunner{}
+}
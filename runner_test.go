@@ -0,0 +1,152 @@
+package nmap
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecRunner_Command(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	runner := ExecRunner{Path: "/opt/nmap/bin/nmap", Prefix: []string{"sh", "-c"}, QuoteArgs: true}
+	cmd, err := runner.Command([]string{"-oX", "-", "--script-args", "http.useragent=it's me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{sh, "-c", `/opt/nmap/bin/nmap -oX - --script-args 'http.useragent=it'\''s me'`}
+	if strings.Join(cmd.Args[1:], "|") != strings.Join(expected[1:], "|") || cmd.Path != sh {
+		t.Errorf("Expected %q, got %q", expected, cmd.Args)
+	}
+}
+
+func TestScan_Run_runner(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	// The script prints a scan of one host instead of running nmap
+	script := `echo '<nmaprun args="nmap localhost"><host><status state="up"/>` +
+		`<address addr="127.0.0.1" addrtype="ipv4"/></host></nmaprun>'`
+	runner := ExecRunner{Path: script, Prefix: []string{"sh", "-c"}}
+
+	scan, err := Init().AddHosts("localhost").SetRunner(runner).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, ok := scan.GetHost("127.0.0.1"); !ok || host.State != "up" {
+		t.Errorf("Host was not parsed from the runner's output: %v", scan.Hosts)
+	}
+
+	runner = ExecRunner{Path: "echo failed >&2; exit 3", Prefix: []string{"sh", "-c"}, Env: os.Environ()}
+	_, err = Init().AddHosts("localhost").SetRunner(runner).Run()
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Exit status was not reported: %v", err)
+	}
+}
+
+func TestScan_Run_stderr(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	// Write more to stderr than a pipe can hold before writing to stdout
+	script := `i=0; while [ $i -lt 5000 ]; do echo "Warning: line $i of a long warning message" >&2; i=$((i+1)); done; ` +
+		`echo '<nmaprun args="nmap localhost"></nmaprun>'; exit 1`
+	runner := ExecRunner{Path: script, Prefix: []string{"sh", "-c"}}
+
+	lines := 0
+	_, err := Init().
+		AddHosts("localhost").
+		SetRunner(runner).
+		SetMaxStderr(100).
+		OnStderr(func(line string) { lines++ }).
+		Run()
+	if lines != 5000 {
+		t.Errorf("Expected 5000 stderr lines, got %d", lines)
+	}
+	if err == nil {
+		t.Fatal("Scan should fail with nmap's exit code")
+	}
+	if !strings.HasSuffix(err.Error(), "line 4999 of a long warning message\n") {
+		t.Errorf("Error should end with the last stderr line: %v", err)
+	}
+	if len(err.Error()) > 120 {
+		t.Errorf("Too much stderr was kept: %d bytes", len(err.Error()))
+	}
+}
+
+func TestTailBuffer_Write(t *testing.T) {
+	b := &tailBuffer{max: 10}
+	for i := 0; i < 100; i++ {
+		b.Write([]byte("abc"))
+		if len(b.data) > 2*b.max {
+			t.Fatalf("Buffer grew to %d bytes", len(b.data))
+		}
+	}
+	b.Write([]byte("0123456789xyz"))
+	if string(b.Bytes()) != "3456789xyz" {
+		t.Errorf("Wrong tail: %q", b.Bytes())
+	}
+}
+
+// waitedProcess is a Process whose stdout fails to read, and that remembers
+// whether it was waited on
+type waitedProcess struct {
+	waited bool
+}
+
+func (p *waitedProcess) Stdout() io.Reader {
+	return io.MultiReader(strings.NewReader("<nmaprun>\n"), failingReader{})
+}
+func (p *waitedProcess) Stderr() io.Reader  { return strings.NewReader("") }
+func (p *waitedProcess) Wait() (int, error) { p.waited = true; return 0, nil }
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("stdout failed") }
+
+// processRunner starts the same Process every time
+type processRunner struct {
+	process Process
+	started int
+}
+
+func (r *processRunner) Start(args []string) (Process, error) {
+	r.started++
+	return r.process, nil
+}
+
+func TestScan_Run_cleanup(t *testing.T) {
+	process := &waitedProcess{}
+	runner := &processRunner{process: process}
+	if _, err := Init().AddHosts("localhost").SetRunner(runner).Run(); err == nil || err.Error() != "stdout failed" {
+		t.Fatalf("Expected the stdout error, got %v", err)
+	}
+	if !process.waited {
+		t.Errorf("nmap was not waited on after stdout failed")
+	}
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	runner = &processRunner{process: &waitedProcess{}}
+	_, err = Init().AddHosts("localhost").SetRunner(runner).Checkpoint(filepath.Join(dir, "missing", "scan.checkpoint")).Run()
+	if err == nil {
+		t.Fatal("Scan should fail when the checkpoint can't be opened")
+	}
+	if runner.started != 0 {
+		t.Errorf("nmap was started even though the checkpoint couldn't be opened")
+	}
+}
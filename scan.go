@@ -3,9 +3,12 @@ package nmap
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"os/exec"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // DisallowedFlagError is thrown when a disallowed flag is used. A list of
@@ -19,34 +22,118 @@ func (f *DisallowedFlagError) Error() string {
 	return "Flag '" + f.Flag + "' is not allowed"
 }
 
+// FlagError is returned by Validate when a flag does not follow nmap's option
+// grammar. Reason describes what is wrong with the flag
+type FlagError struct {
+	Flag   string
+	Reason string
+}
+
+// Error returns the flag and the reason it was rejected
+func (f *FlagError) Error() string {
+	return "Flag '" + f.Flag + "' " + f.Reason
+}
+
 // DisallowedFlags is a list of flags that will break the nmap library's
-// ability to parse the output
-var DisallowedFlags = []string{"-oN", "-oX", "-oG", "-oA"}
+// ability to parse the output. The output flags could write into the XML that
+// nmap prints to stdout, `--resume` ignores the other flags and `--iflist`
+// prints the interfaces instead of scanning.
+var DisallowedFlags = []string{"-oN", "-oX", "-oG", "-oA", "-oS", "--resume", "--iflist"}
 
 // Scan holds one nmap scan. It can be rescanned, diff'ed, and parsed for hosts
 type Scan struct {
 	DisplayArgs string
+	NmapVersion string
 	Hosts       map[string]Host
+	// Warnings are the warnings nmap printed while scanning
+	Warnings []Warning
+	// Stats are nmap's totals for the scan
+	Stats RunStats
 
 	configHosts    []string
 	configPorts    []uint16
 	configTCPPorts []uint16
 	configUDPPorts []uint16
 	configOpts     []string
-	configErr      error
+	configErrs     []error
+
+	configScripts        []string
+	configScriptArgs     ScriptArgs
+	configScriptArgsFile string
+	configScriptTimeout  time.Duration
+	configScriptDB       *ScriptDB
+
+	configPrivileges *Privileges
+	configRunner     Runner
+	configRecordPath string
+	configOnStderr   func(line string)
+	configMaxStderr  int
+	configOnProgress func(Progress)
+	configStatsEvery time.Duration
+	configCheckpoint string
+	configNmapInfo   *NmapInfo
+}
+
+// RunStats are the totals nmap writes when a scan finishes
+type RunStats struct {
+	Start   time.Time
+	End     time.Time
+	Elapsed time.Duration
+	// Summary is nmap's summary line, E.x. `Nmap done at ...; 8 IP addresses
+	// (3 hosts up) scanned in 1.93 seconds`
+	Summary    string
+	HostsUp    int
+	HostsDown  int
+	HostsTotal int
+}
+
+// cleanStats converts the rawRunStats into RunStats
+func (scan rawScan) cleanStats() RunStats {
+	stats := RunStats{
+		Elapsed:    time.Duration(scan.RunStats.Finished.Elapsed * float64(time.Second)),
+		Summary:    scan.RunStats.Finished.Summary,
+		HostsUp:    scan.RunStats.Hosts.Up,
+		HostsDown:  scan.RunStats.Hosts.Down,
+		HostsTotal: scan.RunStats.Hosts.Total,
+	}
+	if start, err := strconv.ParseInt(scan.StartTime, 10, 64); err == nil {
+		stats.Start = unixTime(start)
+	}
+	stats.End = unixTime(scan.RunStats.Finished.Time)
+	return stats
 }
 
 func (scan rawScan) cleanScan(s Scan) Scan {
 	s.DisplayArgs = scan.DisplayArgs
+	s.NmapVersion = scan.Version
+	s.Stats = scan.cleanStats()
+	// Scans made from the same parent share its Hosts map, so each run gets a
+	// new one
+	s.Hosts = make(map[string]Host, len(scan.Hosts))
+	config := s.scanConfig()
 	for _, host := range scan.Hosts {
 		newHost := host.cleanHost()
-		newHost.parentScan = &s
+		newHost.parentScan = config
 		s.Hosts[newHost.Address] = newHost
 	}
 
 	return s
 }
 
+// scanConfig returns a copy of the scan's options without its results. Hosts
+// keep it so that they can be rescanned with the same options. The Record path
+// isn't kept, so a rescan never overwrites the archive of the original scan.
+func (s Scan) scanConfig() *Scan {
+	config := s
+	config.configRecordPath = ""
+	config.DisplayArgs = ""
+	config.NmapVersion = ""
+	config.Hosts = make(map[string]Host)
+	config.Warnings = nil
+	config.Stats = RunStats{}
+	return &config
+}
+
 // Init initializes a scan object. This is the easiest way to create a Scan
 // object. If you are trying to create a Scan object by hand, make sure to
 // instantiate the Hosts map
@@ -56,20 +143,92 @@ func Init() Scan {
 	return scan
 }
 
+// addErr records a problem with the configuration. The error list is copied so
+// that Scans built from the same parent don't share errors.
+func (s Scan) addErr(method, arg string, err error) Scan {
+	errs := make([]error, len(s.configErrs), len(s.configErrs)+1)
+	copy(errs, s.configErrs)
+	s.configErrs = append(errs, &ConfigError{method, arg, err})
+	return s
+}
+
+// methodFamilies groups the builder functions that set the same value. When a
+// Set function replaces the value, the errors from the functions in its family
+// no longer apply.
+var methodFamilies = map[string]string{
+	"AddHosts":         "hosts",
+	"SetHosts":         "hosts",
+	"AddPorts":         "ports",
+	"AddPortRange":     "ports",
+	"SetPorts":         "ports",
+	"AddTCPPorts":      "tcpPorts",
+	"SetTCPPorts":      "tcpPorts",
+	"AddUDPPorts":      "udpPorts",
+	"SetUDPPorts":      "udpPorts",
+	"AddFlags":         "flags",
+	"SetFlags":         "flags",
+	"AddScripts":       "scripts",
+	"SetScripts":       "scripts",
+	"SetScriptTimeout": "scriptTimeout",
+}
+
+// clearErrs removes the errors recorded by the builder functions in the same
+// family as method
+func (s Scan) clearErrs(method string) Scan {
+	family := methodFamilies[method]
+	var errs []error
+	for _, err := range s.configErrs {
+		if configErr, ok := err.(*ConfigError); ok && methodFamilies[configErr.Method] == family {
+			continue
+		}
+		errs = append(errs, err)
+	}
+	s.configErrs = errs
+	return s
+}
+
+// checkHosts records an error for each host that nmap can't use as a target
+func (s Scan) checkHosts(method string, hosts []string) Scan {
+	for _, host := range hosts {
+		if host == "" {
+			s = s.addErr(method, host, errors.New("Host must not be empty"))
+		} else if strings.ContainsAny(host, " \t\n") {
+			s = s.addErr(method, host, errors.New("Host must not have spaces in it"))
+		} else if strings.HasPrefix(host, "-") {
+			s = s.addErr(method, host, errors.New("Host must not start with '-', use AddFlags for flags"))
+		}
+	}
+	return s
+}
+
+// checkPorts records an error for port 0, which nmap can't scan
+func (s Scan) checkPorts(method string, ports []uint16) Scan {
+	for _, port := range ports {
+		if port == 0 {
+			s = s.addErr(method, "0", errors.New("Port 0 is not a valid port"))
+		}
+	}
+	return s
+}
+
 // AddHosts adds a list of hosts to the list of hosts to be scanned
 func (s Scan) AddHosts(hosts ...string) Scan {
+	s = s.checkHosts("AddHosts", hosts)
 	s.configHosts = append(s.configHosts, hosts...)
 	return s
 }
 
 // SetHosts sets the hosts that will be scanned
 func (s Scan) SetHosts(hosts ...string) Scan {
+	s = s.clearErrs("SetHosts")
+	s = s.checkHosts("SetHosts", hosts)
 	s.configHosts = hosts
 	return s
 }
 
 // AddPorts appends a list of ports to the list of ports to be scanned
 func (s Scan) AddPorts(ports ...uint16) Scan {
+	s = s.checkPorts("AddPorts", ports)
 	s.configPorts = append(s.configPorts, ports...)
 	return s
 }
@@ -78,9 +237,17 @@ func (s Scan) AddPorts(ports ...uint16) Scan {
 // (inclusive) on the range and the second argument is the upper bound
 // (exclusive)
 //
-// E.x. AddPortRange(0, 1025) adds ports 1-1024 to the list
+// E.x. AddPortRange(0, 1025) adds ports 1-1024 to the list. Port 0 is never
+// added since nmap can't scan it.
 // TODO(t94j0): Make into actual nmap ranges. (-p1-1024)
 func (s Scan) AddPortRange(lPort, hPort uint16) Scan {
+	if lPort >= hPort {
+		arg := strconv.Itoa(int(lPort)) + "-" + strconv.Itoa(int(hPort))
+		return s.addErr("AddPortRange", arg, errors.New("Low port must be less than the high port"))
+	}
+	if lPort == 0 {
+		lPort = 1
+	}
 	for i := lPort; i < hPort; i++ {
 		s.configPorts = append(s.configPorts, i)
 	}
@@ -89,30 +256,38 @@ func (s Scan) AddPortRange(lPort, hPort uint16) Scan {
 
 // SetPorts sets the ports that wil be used
 func (s Scan) SetPorts(ports ...uint16) Scan {
+	s = s.clearErrs("SetPorts")
+	s = s.checkPorts("SetPorts", ports)
 	s.configPorts = ports
 	return s
 }
 
 // AddTCPPorts adds TCP-only ports. Similar to using `-pT:<port1>,<port2>...`
 func (s Scan) AddTCPPorts(ports ...uint16) Scan {
+	s = s.checkPorts("AddTCPPorts", ports)
 	s.configTCPPorts = append(s.configTCPPorts, ports...)
 	return s
 }
 
 // SetTCPPorts sets which TCP-only ports are used to scan
 func (s Scan) SetTCPPorts(ports ...uint16) Scan {
+	s = s.clearErrs("SetTCPPorts")
+	s = s.checkPorts("SetTCPPorts", ports)
 	s.configTCPPorts = ports
 	return s
 }
 
 // AddUDPPorts adds UDP-only ports. Similar to using `-pU:<port1>,<port2>...`
 func (s Scan) AddUDPPorts(ports ...uint16) Scan {
+	s = s.checkPorts("AddUDPPorts", ports)
 	s.configUDPPorts = append(s.configUDPPorts, ports...)
 	return s
 }
 
 // SetUDPPort sets which TCP-only ports are used to scan
 func (s Scan) SetUDPPorts(ports ...uint16) Scan {
+	s = s.clearErrs("SetUDPPorts")
+	s = s.checkPorts("SetUDPPorts", ports)
 	s.configUDPPorts = ports
 	return s
 }
@@ -124,28 +299,41 @@ func (s Scan) SetUDPPorts(ports ...uint16) Scan {
 // Use the DisallowedFlags variable to guide you on which flags are not allowed
 // to be used.
 func (s Scan) AddFlags(flags ...string) Scan {
+	return s.addFlags("AddFlags", flags)
+}
+
+// SetFlags replaces the flags used by nmap. See AddFlags for how the flags
+// should be given. Like the other Set functions, it drops the errors of the
+// flags it replaces.
+func (s Scan) SetFlags(flags ...string) Scan {
+	s = s.clearErrs("SetFlags")
+	s.configOpts = []string{}
+	return s.addFlags("SetFlags", flags)
+}
+
+// addFlags checks and appends flags. method is the builder function that was
+// called, so that errors can say where the flag came from.
+func (s Scan) addFlags(method string, flags []string) Scan {
+	valid := true
 	for _, flag := range flags {
 		if strings.Contains(flag, " ") {
-			s.configErr = errors.New("Flags must not have spaces in them")
-			return s
+			s = s.addErr(method, flag, errors.New("Flags must not have spaces in them"))
+			valid = false
 		}
 		for _, df := range DisallowedFlags {
 			if flag == df {
-				s.configErr = &DisallowedFlagError{df}
-				return s
+				s = s.addErr(method, flag, &DisallowedFlagError{df})
+				valid = false
 			}
 		}
 	}
 
-	s.configOpts = append(s.configOpts, flags...)
+	if valid {
+		s.configOpts = append(s.configOpts, flags...)
+	}
 	return s
 }
 
-func (s Scan) SetFlags(flags ...string) Scan {
-	s.configOpts = []string{}
-	return s.AddFlags(flags...)
-}
-
 // Intense sets the options to use an "intense" scan. These are the same
 // options as used in Zenmap's intense scan.
 func (s Scan) Intense() Scan {
@@ -171,59 +359,154 @@ func (s Scan) Quick() Scan {
 	return s.SetFlags("-T4", "-F")
 }
 
+// Validate checks the scan configuration before it is run. Every problem
+// found by the Add* and Set* functions is reported, along with flags that
+// don't follow nmap's option grammar: unknown flags, flags missing their
+// argument and flags that cannot be used together. The returned error is a
+// ConfigErrors holding all of the problems.
+func (s Scan) Validate() error {
+	errs := append(ConfigErrors{}, s.configErrs...)
+
+	flags, flagErrs := parseFlags(s.configOpts)
+	errs = append(errs, flagErrs...)
+	errs = append(errs, checkConflicts(flags)...)
+
+	hasPorts := len(s.configPorts) != 0 || len(s.configTCPPorts) != 0 || len(s.configUDPPorts) != 0
+	if hasPorts && hasFlag(flags, "-p") {
+		errs = append(errs, &FlagError{"-p", "cannot be used when ports are added with the Add*Ports functions"})
+	}
+	if mode := scanMode(flags); hasPorts && mode != "" && mode != modePortScan {
+		errs = append(errs, errors.New("Ports cannot be scanned during a "+mode))
+	}
+
+	// A script flag can't also be set with the script function that makes it.
+	// scriptArgs is pairs of a flag and its value.
+	scriptFlags := s.scriptArgs()
+	for i := 0; i < len(scriptFlags); i += 2 {
+		if name := scriptFlags[i]; hasFlag(flags, name) {
+			errs = append(errs, &FlagError{name, "cannot be used when it is also set with the *Script* functions"})
+		}
+	}
+
+	errs = append(errs, s.checkScriptDB()...)
+	errs = append(errs, s.checkNmapInfo(flags)...)
+	if err := s.checkPrivileges(flags); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(s.configHosts) == 0 && !hasFlag(flags, "-iL") && !hasFlag(flags, "-iR") {
+		errs = append(errs, errors.New("No hosts added"))
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// PrivilegedFlags returns the configured flags that nmap will only run as
+// root
+func (s Scan) PrivilegedFlags() []string {
+	flags, _ := parseFlags(s.configOpts)
+
+	var privileged []string
+	for _, f := range flags {
+		if f.option.Privileged {
+			privileged = append(privileged, f.flag)
+		}
+	}
+	return privileged
+}
+
 // Run is used to scan hosts. The Scan object should be configured using
-// specified Add* Set* functions.
-//
-// BUG(t94j0): The scan will sometimes segfault and theres no reason why
+// specified Add* Set* functions. If the configuration has problems, nmap is not
+// run and the ConfigErrors from Validate are returned. nmap is started with the
+// Runner given to SetRunner. When OnProgress is used, progress updates are sent
+// while nmap is running, and when Checkpoint is used, finished hosts are saved
+// as they are found. When Record is used, the run is saved before the
+// output is parsed.
 func (s Scan) Run() (output Scan, err error) {
-	if s.configErr != nil {
-		return s, s.configErr
+	if output, err = s.run(); err != nil {
+		return output, err
 	}
 
-	args, err := s.CreateNmapArgs()
-	if err != nil {
-		return s, err
+	// The scan finished, so there is nothing left to resume
+	if s.configCheckpoint != "" {
+		err = os.Remove(s.configCheckpoint)
 	}
+	return output, err
+}
 
-	// Find path for nmap binary
-	nmapPath, err := exec.LookPath("nmap")
+// run runs nmap once and parses its output
+func (s Scan) run() (output Scan, err error) {
+	args, err := s.CreateNmapArgs()
 	if err != nil {
 		return s, err
 	}
 
-	cmd := exec.Command(nmapPath, args...)
+	// The checkpoint is opened before nmap is started, so nmap is never left
+	// running when it can't be opened
+	var checkpoint *checkpointWriter
+	if s.configCheckpoint != "" {
+		if checkpoint, err = openCheckpoint(s.configCheckpoint); err != nil {
+			return s, err
+		}
+	}
 
-	// Configure output pipes
-	errPipe, err := cmd.StderrPipe()
+	process, err := s.runner().Start(args)
 	if err != nil {
+		if checkpoint != nil {
+			checkpoint.Close()
+		}
 		return s, err
 	}
 
-	outPipe, err := cmd.StdoutPipe()
+	// Read output. stderr is read at the same time so that neither pipe can
+	// fill up and block nmap
+	stderrDone := s.drainStderr(process.Stderr())
+	var watchers []io.Writer
+	if s.configOnProgress != nil {
+		watchers = append(watchers, &progressWriter{callback: s.configOnProgress})
+	}
+	if checkpoint != nil {
+		watchers = append(watchers, checkpoint)
+	}
+	stdoutReader := process.Stdout()
+	if len(watchers) != 0 {
+		stdoutReader = io.TeeReader(stdoutReader, io.MultiWriter(watchers...))
+	}
+	stdout, err := ioutil.ReadAll(stdoutReader)
 	if err != nil {
-		return s, err
+		// Read the rest of stdout so that nmap can exit and be waited on
+		io.Copy(ioutil.Discard, process.Stdout())
 	}
-
-	// Start command
-	if err := cmd.Start(); err != nil {
-		return s, err
+	stderrOut := <-stderrDone
+	stderr := stderrOut.data
+	if checkpoint != nil {
+		if closeErr := checkpoint.Close(); err == nil {
+			err = closeErr
+		}
 	}
 
-	// Read output
-	stdout, err := ioutil.ReadAll(outPipe)
+	// Wait on command to be finished
+	exitCode, waitErr := process.Wait()
 	if err != nil {
 		return s, err
 	}
+	if waitErr != nil {
+		return s, waitErr
+	}
 
-	stderr, err := ioutil.ReadAll(errPipe)
-	if err != nil {
-		return s, err
+	// Save the session before parsing, so failed runs are recorded too
+	if s.configRecordPath != "" {
+		if err := s.recordSession(args, stdout, stderr, exitCode); err != nil {
+			return s, err
+		}
 	}
 
-	// Wait on command to be finished
-	if err := cmd.Wait(); err != nil {
-		fmt.Println(err)
-		return s, errors.New(err.Error() + "\n" + string(stderr))
+	s.Warnings = stderrOut.warnings
+	if exitCode != 0 {
+		return s, exitError(exitCode, stderr, stderrOut.warnings)
 	}
 
 	// Parse command output
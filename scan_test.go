@@ -1,30 +1,93 @@
 package nmap
 
 import (
-	"fmt"
+	"errors"
+	"testing"
+	"time"
 )
 
-func ExampleScan_GetHost() {
-	// All online hosts are added to the `scan` object
-	scan, _ := Init().AddPorts(80).AddHosts("example.com").Run()
-	// GetHost allows you to select one host from the list
-	targetHost, _ := scan.GetHost("93.184.216.34")
-	fmt.Println(targetHost.ToString())
-	// It also searches hostnames
-	targetHost, _ = scan.GetHost("maxh.io")
-	fmt.Println(targetHost.ToString())
+func TestScan_Validate_collectserrors(t *testing.T) {
+	err := Init().
+		AddFlags("-oX").
+		AddFlags("-sV 2").
+		AddPorts(0).
+		Validate()
+
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("Validate should return ConfigErrors, got %v", err)
+	}
+	// -oX, the flag with a space, port 0 and the missing hosts
+	if len(errs) != 4 {
+		t.Fatalf("Expected 4 errors, got %d:\n%s", len(errs), errs)
+	}
+
+	var disallowed *DisallowedFlagError
+	if !errors.As(errs[0], &disallowed) || disallowed.Flag != "-oX" {
+		t.Errorf("First error should be for -oX: %s", errs[0])
+	}
+	var configErr *ConfigError
+	if !errors.As(errs[1], &configErr) || configErr.Method != "AddFlags" {
+		t.Errorf("Second error should record the AddFlags call: %s", errs[1])
+	}
+}
+
+func TestScan_AddFlags_disallowed(t *testing.T) {
+	for _, flags := range [][]string{
+		{"-oS", "-"},
+		{"-oN", "-"},
+		{"--resume", "scan.xml"},
+		{"--iflist"},
+	} {
+		err := Init().AddHosts("localhost").AddFlags(flags...).Validate()
+		var disallowed *DisallowedFlagError
+		if !errors.As(err, &disallowed) || disallowed.Flag != flags[0] {
+			t.Errorf("%v should not be allowed: %v", flags, err)
+		}
+	}
 }
 
-func ExampleScan_Run() {
-	scan, _ := Init().
-		AddHosts("maxh.io", "192.168.0.1").
-		AddPorts(80, 445).
-		AddFlags("-A").
-		Run()
-	fmt.Println(scan.ToString())
+func TestScan_Set_replaceserrors(t *testing.T) {
+	scan := Init().AddHosts("").SetHosts("localhost").
+		AddPorts(0).SetPorts(22).
+		AddUDPPorts(0).SetUDPPorts(53).
+		AddFlags("-oX").SetFlags("-T4").
+		AddScripts("").SetScripts("default").
+		SetScriptTimeout(-1).SetScriptTimeout(time.Minute)
+	if err := scan.Validate(); err != nil {
+		t.Errorf("Replaced values should not be reported: %v", err)
+	}
+
+	// Errors from other functions are kept
+	err := Init().AddHosts("localhost").AddPorts(0).SetFlags("-T4").Validate()
+	if errs, ok := err.(ConfigErrors); !ok || len(errs) != 1 {
+		t.Errorf("Expected the AddPorts error to be kept, got %v", err)
+	}
+}
+
+func TestConfigErrors_IsAs(t *testing.T) {
+	errs := ConfigErrors{
+		&ConfigError{"AddPorts", "0", errors.New("Port 0 cannot be scanned")},
+		&ConfigError{"AddFlags", "-T4", ErrNmapNotFound},
+	}
+
+	// Is and As are called directly, since errors.Is and errors.As only use
+	// Unwrap() []error on newer Go versions
+	var configErr *ConfigError
+	if !errs.As(&configErr) || configErr.Method != "AddPorts" {
+		t.Errorf("Expected the first ConfigError, got %v", configErr)
+	}
+	if !errs.Is(ErrNmapNotFound) {
+		t.Error("Expected ErrNmapNotFound to be found")
+	}
+	var exitErr *ExitError
+	if errs.As(&exitErr) || ShardErrors(errs).As(&exitErr) {
+		t.Error("No ExitError should be found")
+	}
 }
 
-func ExampleScan_Intense() {
-	scan, _ := Init().AddHosts("localhost").Intense().Run()
-	fmt.Println(scan.ToString())
+func TestScan_CreateNmapArgs_nohosts(t *testing.T) {
+	if _, err := Init().AddPorts(80).CreateNmapArgs(); err == nil {
+		t.Errorf("Scan without hosts should not create arguments")
+	}
 }
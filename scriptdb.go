@@ -0,0 +1,461 @@
+package nmap
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DataDirs is the list of directories where nmap is normally installed. They
+// are searched by FindDataDir after the NMAPDIR environment variable, the
+// user's `~/.nmap` directory and the directories next to the nmap binary.
+var DataDirs = []string{
+	"/usr/local/share/nmap",
+	"/usr/share/nmap",
+	"/opt/homebrew/share/nmap",
+	"/opt/local/share/nmap",
+	`C:\Program Files (x86)\Nmap`,
+	`C:\Program Files\Nmap`,
+}
+
+// ErrDataDirNotFound is returned when nmap's data directory can't be found
+var ErrDataDirNotFound = errors.New("Could not find the nmap data directory")
+
+// ScriptInfo is the information about an installed NSE script
+type ScriptInfo struct {
+	// Name is the script name without the `.nse` extension
+	Name        string
+	Path        string
+	Description string
+	Categories  []string
+	Authors     []string
+	Args        []ScriptArgInfo
+}
+
+// ScriptArgInfo is an argument documented by a script or NSE library
+type ScriptArgInfo struct {
+	Name        string
+	Description string
+}
+
+// ScriptDB holds the NSE scripts and libraries installed with nmap
+type ScriptDB struct {
+	Dir string
+
+	scripts map[string]ScriptInfo
+	args    map[string]ScriptArgInfo
+}
+
+// UnknownScriptError is returned by Validate when a script selection doesn't
+// match any installed script, category or file
+type UnknownScriptError struct {
+	Script string
+}
+
+// Error returns the script name
+func (u *UnknownScriptError) Error() string {
+	return "Script '" + u.Script + "' is not installed"
+}
+
+// UnknownScriptArgError is returned by Validate when a script argument isn't
+// used by any installed script or library
+type UnknownScriptArgError struct {
+	Arg string
+}
+
+// Error returns the argument name
+func (u *UnknownScriptArgError) Error() string {
+	return "Script argument '" + u.Arg + "' is not used by any installed script"
+}
+
+// FindDataDir finds nmap's data directory the same way nmap does. The NMAPDIR
+// environment variable is checked first, then `~/.nmap`, then the directories
+// next to the nmap binary, then DataDirs.
+func FindDataDir() (string, error) {
+	nmapPath, _ := exec.LookPath("nmap")
+	return findDataDir(nmapPath)
+}
+
+// DataDir finds the data directory like FindDataDir, but looks next to the nmap
+// binary that the scan's ExecRunner runs
+func (s Scan) DataDir() (string, error) {
+	return findDataDir(s.nmapPath())
+}
+
+// nmapPath returns the nmap binary set on the scan's ExecRunner. The PATH is
+// searched when the runner doesn't set one, or isn't an ExecRunner.
+func (s Scan) nmapPath() string {
+	if runner, ok := s.runner().(ExecRunner); ok && runner.Path != "" {
+		return runner.Path
+	}
+	nmapPath, _ := exec.LookPath("nmap")
+	return nmapPath
+}
+
+// findDataDir finds the data directory, looking next to nmapPath when it is
+// set
+func findDataDir(nmapPath string) (string, error) {
+	var candidates []string
+	if dir := os.Getenv("NMAPDIR"); dir != "" {
+		candidates = append(candidates, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".nmap"))
+	}
+	if nmapPath != "" {
+		if resolved, err := filepath.EvalSymlinks(nmapPath); err == nil {
+			nmapPath = resolved
+		}
+		binDir := filepath.Dir(nmapPath)
+		candidates = append(candidates, binDir, filepath.Join(binDir, "..", "share", "nmap"))
+	}
+	candidates = append(candidates, DataDirs...)
+
+	for _, dir := range candidates {
+		if isDataDir(dir) {
+			return filepath.Clean(dir), nil
+		}
+	}
+	return "", ErrDataDirNotFound
+}
+
+// isDataDir returns true when the directory has nmap's data files
+func isDataDir(dir string) bool {
+	for _, name := range []string{"nmap-services", filepath.Join("scripts", "script.db")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadScriptDB reads `scripts/script.db` from nmap's data directory along with
+// the headers of each script and NSE library. If dir is empty, FindDataDir is
+// used to find the directory.
+func LoadScriptDB(dir string) (*ScriptDB, error) {
+	if dir == "" {
+		found, err := FindDataDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = found
+	}
+
+	db := &ScriptDB{
+		Dir:     dir,
+		scripts: make(map[string]ScriptInfo),
+		args:    make(map[string]ScriptArgInfo),
+	}
+
+	entries, err := readScriptDBEntries(filepath.Join(dir, "scripts", "script.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		info, err := readScriptHeader(filepath.Join(dir, "scripts", entry.filename))
+		if err != nil {
+			return nil, err
+		}
+		info.Categories = entry.categories
+		db.scripts[info.Name] = info
+		for _, arg := range info.Args {
+			db.args[arg.Name] = arg
+		}
+	}
+
+	// Libraries document arguments shared by many scripts (E.x. http.useragent)
+	libs, _ := filepath.Glob(filepath.Join(dir, "nselib", "*.lua*"))
+	for _, lib := range libs {
+		info, err := readScriptHeader(lib)
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range info.Args {
+			db.args[arg.Name] = arg
+		}
+	}
+
+	return db, nil
+}
+
+// Script gets an installed script by name. The `.nse` extension is optional.
+func (db *ScriptDB) Script(name string) (ScriptInfo, bool) {
+	info, ok := db.scripts[strings.TrimSuffix(name, ".nse")]
+	return info, ok
+}
+
+// Scripts returns every installed script sorted by name
+func (db *ScriptDB) Scripts() []ScriptInfo {
+	scripts := make([]ScriptInfo, 0, len(db.scripts))
+	for _, info := range db.scripts {
+		scripts = append(scripts, info)
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+	return scripts
+}
+
+// Categories returns the name of every category used by an installed script
+func (db *ScriptDB) Categories() []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, info := range db.scripts {
+		for _, category := range info.Categories {
+			if !seen[category] {
+				seen[category] = true
+				categories = append(categories, category)
+			}
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// Category returns the scripts in a category sorted by name
+func (db *ScriptDB) Category(category string) []ScriptInfo {
+	var scripts []ScriptInfo
+	for _, info := range db.Scripts() {
+		for _, c := range info.Categories {
+			if c == category {
+				scripts = append(scripts, info)
+				break
+			}
+		}
+	}
+	return scripts
+}
+
+// Arg gets the documentation for a script argument
+func (db *ScriptDB) Arg(name string) (ScriptArgInfo, bool) {
+	info, ok := db.args[name]
+	return info, ok
+}
+
+// CheckSelection returns an UnknownScriptError when a selection given to
+// `--script` doesn't match anything installed. Boolean expressions are split
+// into their names, and each name must be `all`, a category, a script, a glob
+// matching a script or a path that exists.
+func (db *ScriptDB) CheckSelection(selection string) error {
+	for _, term := range selectionTerms(selection) {
+		if !db.selects(term) {
+			return &UnknownScriptError{term}
+		}
+	}
+	return nil
+}
+
+// selects returns true when a single term of a selection matches something
+func (db *ScriptDB) selects(term string) bool {
+	term = strings.TrimPrefix(term, "+")
+	if term == "all" {
+		return true
+	}
+	if _, ok := db.Script(term); ok {
+		return true
+	}
+	for _, category := range db.Categories() {
+		if category == term {
+			return true
+		}
+	}
+	if strings.ContainsAny(term, "*?[") {
+		for name := range db.scripts {
+			if ok, _ := path.Match(strings.TrimSuffix(term, ".nse"), name); ok {
+				return true
+			}
+		}
+	}
+	if strings.ContainsAny(term, `/\`) || strings.HasSuffix(term, ".nse") {
+		if _, err := os.Stat(term); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckArg returns an UnknownScriptArgError when no installed script or
+// library uses the argument. An argument is known when it is documented, or
+// when it is prefixed by the name of a script (E.x. `http-title.url`).
+// Library arguments must be documented.
+func (db *ScriptDB) CheckArg(name string) error {
+	if _, ok := db.args[name]; ok {
+		return nil
+	}
+	if i := strings.Index(name, "."); i != -1 {
+		if _, ok := db.scripts[name[:i]]; ok {
+			return nil
+		}
+	}
+	return &UnknownScriptArgError{name}
+}
+
+// ScriptDB loads the ScriptDB from the data directory of the nmap that the scan
+// runs, see DataDir. Pass it to SetScriptDB to check the scan's scripts.
+func (s Scan) ScriptDB() (*ScriptDB, error) {
+	dir, err := s.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	return LoadScriptDB(dir)
+}
+
+// SetScriptDB makes Validate check every script selection and script argument
+// against the installed scripts, so that typos are found before nmap is run.
+func (s Scan) SetScriptDB(db *ScriptDB) Scan {
+	s.configScriptDB = db
+	return s
+}
+
+// checkScriptDB checks the configured scripts against the ScriptDB
+func (s Scan) checkScriptDB() (errs []error) {
+	if s.configScriptDB == nil {
+		return nil
+	}
+	for _, selection := range s.configScripts {
+		if err := s.configScriptDB.CheckSelection(selection); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	names := make([]string, 0, len(s.configScriptArgs))
+	for name := range s.configScriptArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := s.configScriptDB.CheckArg(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return
+}
+
+// selectionTerms splits a script selection into the names used in it
+func selectionTerms(selection string) (terms []string) {
+	fields := strings.FieldsFunc(selection, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '(' || r == ')'
+	})
+	for _, field := range fields {
+		switch field {
+		case "and", "or", "not":
+		default:
+			terms = append(terms, field)
+		}
+	}
+	return
+}
+
+// scriptDBEntry is a line of script.db
+type scriptDBEntry struct {
+	filename   string
+	categories []string
+}
+
+var (
+	scriptDBFilename = regexp.MustCompile(`filename\s*=\s*"([^"]+)"`)
+	scriptDBCategory = regexp.MustCompile(`categories\s*=\s*\{([^}]*)\}`)
+	luaString        = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'`)
+)
+
+// readScriptDBEntries parses script.db. Each line looks like
+// `Entry { filename = "ftp-anon.nse", categories = { "auth", "safe", } }`
+func readScriptDBEntries(dbPath string) ([]scriptDBEntry, error) {
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []scriptDBEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		filename := scriptDBFilename.FindStringSubmatch(line)
+		if filename == nil {
+			continue
+		}
+		entry := scriptDBEntry{filename: filename[1]}
+		if categories := scriptDBCategory.FindStringSubmatch(line); categories != nil {
+			entry.categories = luaStrings(categories[1])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+var (
+	scriptDescription = regexp.MustCompile(`(?:^|\n)description\s*=\s*(?:\[(=*)\[|"((?:[^"\\]|\\.)*)")`)
+	scriptAuthor      = regexp.MustCompile(`(?s)(?:^|\n)author\s*=\s*(\{[^}]*\}|"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')`)
+	scriptCategories  = regexp.MustCompile(`(?s)(?:^|\n)categories\s*=\s*\{([^}]*)\}`)
+	scriptArgDoc      = regexp.MustCompile(`^---?\s*@args?\s+(\S+)\s*(.*)$`)
+	scriptDocLine     = regexp.MustCompile(`^--\s+(\S.*)$`)
+)
+
+// readScriptHeader reads the description, authors, categories and documented
+// arguments of an NSE script or library
+func readScriptHeader(scriptPath string) (ScriptInfo, error) {
+	contents, err := ioutil.ReadFile(scriptPath)
+	if err != nil {
+		return ScriptInfo{}, err
+	}
+	source := string(contents)
+
+	name := filepath.Base(scriptPath)
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".nse"), ".luadoc")
+	name = strings.TrimSuffix(name, ".lua")
+	info := ScriptInfo{Name: name, Path: scriptPath}
+
+	if m := scriptDescription.FindStringSubmatchIndex(source); m != nil {
+		if m[2] != -1 {
+			// Long strings end with the same number of '=' they started with
+			closing := "]" + source[m[2]:m[3]] + "]"
+			body := source[m[1]:]
+			if end := strings.Index(body, closing); end != -1 {
+				info.Description = strings.TrimSpace(body[:end])
+			}
+		} else {
+			info.Description = strings.TrimSpace(source[m[4]:m[5]])
+		}
+	}
+	if m := scriptAuthor.FindStringSubmatch(source); m != nil {
+		info.Authors = luaStrings(m[1])
+	}
+	if m := scriptCategories.FindStringSubmatch(source); m != nil {
+		info.Categories = luaStrings(m[1])
+	}
+
+	// @args lines can continue on the following comment lines
+	current := -1
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := scriptArgDoc.FindStringSubmatch(line); m != nil {
+			info.Args = append(info.Args, ScriptArgInfo{m[1], strings.TrimSpace(m[2])})
+			current = len(info.Args) - 1
+			continue
+		}
+		if m := scriptDocLine.FindStringSubmatch(line); m != nil && current != -1 &&
+			!strings.HasPrefix(m[1], "@") {
+			arg := &info.Args[current]
+			arg.Description = strings.TrimSpace(arg.Description + " " + m[1])
+			continue
+		}
+		current = -1
+	}
+
+	return info, nil
+}
+
+// luaStrings returns the strings in a Lua string or table of strings
+func luaStrings(source string) (values []string) {
+	for _, m := range luaString.FindAllStringSubmatch(source, -1) {
+		values = append(values, m[1]+m[2])
+	}
+	return
+}
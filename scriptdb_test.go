@@ -0,0 +1,166 @@
+package nmap
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testHTTPTitle = `local http = require "http"
+
+description = [[
+Shows the title of the default page of a web server.
+]]
+
+---
+-- @usage
+-- nmap --script=http-title <target>
+--
+-- @args http-title.url The url to fetch. Default: /
+-- @args http-title.other Another argument that is documented
+--       over two lines
+--
+-- @output
+-- |_http-title: Welcome
+
+author = {"Diman Todorov", "Brandon Enright"}
+license = "Same as Nmap--See https://nmap.org/book/man-legal.html"
+categories = {"default", "discovery", "safe"}
+`
+
+const testFTPAnon = `description = "Checks if an FTP server allows anonymous logins."
+author = "Eddie Bell"
+categories = {"default", "auth", "safe"}
+`
+
+const testHTTPLib = `---
+-- Implements the HTTP client protocol.
+--
+-- @args http.useragent The value of the User-Agent header field.
+local _ENV = stdnse.module("http", stdnse.seeall)
+`
+
+// writeTestDataDir creates an nmap data directory with a couple of scripts
+func writeTestDataDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "nmap-datadir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"nmap-services": "http\t80/tcp\t0.484143\n",
+		"scripts/script.db": `Entry { filename = "ftp-anon.nse", categories = { "auth", "default", "safe", } }
+Entry { filename = "http-title.nse", categories = { "default", "discovery", "safe", } }
+`,
+		"scripts/http-title.nse": testHTTPTitle,
+		"scripts/ftp-anon.nse":   testFTPAnon,
+		"nselib/http.lua":        testHTTPLib,
+	}
+	for name, contents := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadScriptDB(t *testing.T) {
+	dir := writeTestDataDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := LoadScriptDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title, ok := db.Script("http-title.nse")
+	if !ok {
+		t.Fatal("http-title was not loaded")
+	}
+	if title.Description != "Shows the title of the default page of a web server." {
+		t.Errorf("Wrong description: %q", title.Description)
+	}
+	if len(title.Authors) != 2 || title.Authors[1] != "Brandon Enright" {
+		t.Errorf("Wrong authors: %v", title.Authors)
+	}
+	if len(title.Args) != 2 || title.Args[1].Description != "Another argument that is documented over two lines" {
+		t.Errorf("Wrong args: %v", title.Args)
+	}
+
+	anon, _ := db.Script("ftp-anon")
+	if anon.Description != "Checks if an FTP server allows anonymous logins." || len(anon.Authors) != 1 {
+		t.Errorf("Wrong ftp-anon header: %+v", anon)
+	}
+
+	if len(db.Category("default")) != 2 || len(db.Category("auth")) != 1 {
+		t.Errorf("Wrong categories: %v", db.Categories())
+	}
+}
+
+func TestScan_SetScriptDB(t *testing.T) {
+	dir := writeTestDataDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := LoadScriptDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := Init().
+		AddHosts("localhost").
+		SetScriptDB(db).
+		AddScripts("default and not auth", "http-*", "ftp-anon").
+		AddScriptArgs(ScriptArgs{
+			"http.useragent":   "test",
+			"http-title.url":   "/",
+			"ftp-anon.maxlist": 10,
+		})
+	if err := scan.Validate(); err != nil {
+		t.Errorf("Installed scripts were rejected: %s", err)
+	}
+
+	err = scan.AddScripts("http-titel").SetScriptArg("http.useragnet", "test").Validate()
+	var unknownScript *UnknownScriptError
+	if !errors.As(err, &unknownScript) || unknownScript.Script != "http-titel" {
+		t.Errorf("Unknown script was not reported: %v", err)
+	}
+	var unknownArg *UnknownScriptArgError
+	if !errors.As(err, &unknownArg) || unknownArg.Arg != "http.useragnet" {
+		t.Errorf("Unknown script argument was not reported: %v", err)
+	}
+}
+
+func TestScan_ScriptDB_runnerPath(t *testing.T) {
+	dir := writeTestDataDir(t)
+	defer os.RemoveAll(dir)
+
+	// nmap's data files are next to the binary, which isn't in the PATH
+	for _, name := range []string{"NMAPDIR", "HOME"} {
+		old, set := os.LookupEnv(name)
+		os.Unsetenv(name)
+		defer func(name string) {
+			if set {
+				os.Setenv(name, old)
+			}
+		}(name)
+	}
+	scan := Init().SetRunner(ExecRunner{Path: filepath.Join(dir, "nmap")})
+
+	found, err := scan.DataDir()
+	if err != nil || found != filepath.Clean(dir) {
+		t.Fatalf("Expected data dir %s, got %s: %v", dir, found, err)
+	}
+	db, err := scan.ScriptDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.Script("http-title"); !ok {
+		t.Errorf("Scripts were not loaded from the runner's data dir")
+	}
+}
@@ -0,0 +1,324 @@
+package nmap
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the files inside of a session archive
+const (
+	sessionInfoFile   = "session.json"
+	sessionStdoutFile = "stdout.xml"
+	sessionStderrFile = "stderr.txt"
+)
+
+// SensitiveEnv is the list of words that mark an environment variable as
+// sensitive. Variables with one of these words in their name are not saved in
+// session archives.
+var SensitiveEnv = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "KEY", "CREDENTIAL", "AUTH", "COOKIE", "SESSION"}
+
+// Session is everything that happened during one nmap run. Sessions are saved
+// by Record and loaded by LoadSession.
+type Session struct {
+	// Args are the arguments nmap was run with
+	Args []string `json:"args"`
+	// Env is the environment nmap was run with, without sensitive variables
+	Env         []string   `json:"env"`
+	NmapVersion string     `json:"nmap_version"`
+	Privileges  Privileges `json:"privileges"`
+	ExitCode    int        `json:"exit_code"`
+	Time        time.Time  `json:"time"`
+
+	Stdout []byte `json:"-"`
+	Stderr []byte `json:"-"`
+}
+
+// Record saves the nmap run to an archive at path when the scan is run. The
+// archive can be loaded with Replay to get the same Scan back without running
+// nmap.
+func (s Scan) Record(path string) Scan {
+	s.configRecordPath = path
+	return s
+}
+
+// Replay loads a session archive saved by Record, and parses it into the same
+// Scan that was returned when it was recorded. nmap is not run.
+func Replay(path string) (Scan, error) {
+	session, err := LoadSession(path)
+	if err != nil {
+		return Scan{}, err
+	}
+	return session.Scan()
+}
+
+// Scan rebuilds the Scan configuration from the session's arguments and
+// parses the recorded output into it
+func (session *Session) Scan() (Scan, error) {
+	scan, err := ParseArgs(session.Args)
+	if err != nil {
+		return scan, err
+	}
+	return scan.SetPrivileges(session.Privileges).SetRunner(replayRunner{session}).Run()
+}
+
+// Save writes the session to an archive. The archive is a gzipped tar file
+// holding the session information as JSON, along with nmap's stdout and
+// stderr.
+func (session *Session) Save(path string) error {
+	info, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{sessionInfoFile, info},
+		{sessionStdoutFile, session.Stdout},
+		{sessionStderrFile, session.Stderr},
+	}
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0600,
+			Size:    int64(len(file.data)),
+			ModTime: session.Time,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// LoadSession reads a session archive saved by Record
+func LoadSession(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	session := &Session{}
+	foundInfo := false
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		switch header.Name {
+		case sessionInfoFile:
+			if err := json.Unmarshal(data, session); err != nil {
+				return nil, err
+			}
+			foundInfo = true
+		case sessionStdoutFile:
+			session.Stdout = data
+		case sessionStderrFile:
+			session.Stderr = data
+		}
+	}
+
+	if !foundInfo {
+		return nil, errors.New("Session archive is missing " + sessionInfoFile)
+	}
+	return session, nil
+}
+
+// recordSession saves a session for a finished nmap run
+func (s Scan) recordSession(args []string, stdout, stderr []byte, exitCode int) error {
+	env := os.Environ()
+	if runner, ok := s.runner().(ExecRunner); ok && runner.Env != nil {
+		env = runner.Env
+	}
+
+	session := &Session{
+		Args:       args,
+		Env:        filterEnv(env),
+		Privileges: s.Privileges(),
+		ExitCode:   exitCode,
+		Time:       time.Now().UTC(),
+		Stdout:     stdout,
+		Stderr:     stderr,
+	}
+	if raw, err := parseXML(stdout); err == nil {
+		session.NmapVersion = raw.Version
+	}
+
+	return session.Save(s.configRecordPath)
+}
+
+// filterEnv removes sensitive variables from an environment
+func filterEnv(env []string) (filtered []string) {
+	for _, variable := range env {
+		name := strings.ToUpper(strings.SplitN(variable, "=", 2)[0])
+		sensitive := false
+		for _, word := range SensitiveEnv {
+			if strings.Contains(name, word) {
+				sensitive = true
+				break
+			}
+		}
+		if !sensitive {
+			filtered = append(filtered, variable)
+		}
+	}
+	return
+}
+
+// replayRunner is a Runner that returns the output of a session instead of
+// running nmap
+type replayRunner struct {
+	session *Session
+}
+
+func (r replayRunner) Start(args []string) (Process, error) {
+	return &replayProcess{
+		bytes.NewReader(r.session.Stdout),
+		bytes.NewReader(r.session.Stderr),
+		r.session.ExitCode,
+	}, nil
+}
+
+func (r replayRunner) Privileges() Privileges {
+	return r.session.Privileges
+}
+
+type replayProcess struct {
+	stdout   io.Reader
+	stderr   io.Reader
+	exitCode int
+}
+
+func (p *replayProcess) Stdout() io.Reader  { return p.stdout }
+func (p *replayProcess) Stderr() io.Reader  { return p.stderr }
+func (p *replayProcess) Wait() (int, error) { return p.exitCode, nil }
+
+// ParseArgs builds a Scan from nmap arguments, such as the ones made by
+// CreateNmapArgs. The `-p` port list is split into the Add*Ports functions,
+// arguments that aren't flags are added as hosts, and the rest are added as
+// flags.
+func ParseArgs(args []string) (Scan, error) {
+	scan := Init()
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			scan = scan.AddHosts(arg)
+			continue
+		}
+
+		// The XML output is always added by CreateNmapArgs
+		if arg == "-oX" {
+			i++
+			continue
+		}
+
+		opt, value, ok := lookupOption(arg)
+		if ok && opt.Name == "-p" {
+			if value == "" && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			var err error
+			if scan, err = scan.addPortList(value); err != nil {
+				return scan, err
+			}
+			continue
+		}
+
+		flags := []string{arg}
+		if ok && opt.Arity == 1 && value == "" && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+		scan = scan.AddFlags(flags...)
+	}
+
+	return scan, nil
+}
+
+// addPortList adds the ports in an nmap port list, E.x. `22,80,U:53,T:443`
+func (s Scan) addPortList(list string) (Scan, error) {
+	protocol := ""
+	for _, item := range strings.Split(list, ",") {
+		if i := strings.Index(item, ":"); i != -1 {
+			protocol, item = item[:i], item[i+1:]
+		}
+		if item == "" {
+			continue
+		}
+
+		low, high := item, item
+		if i := strings.Index(item, "-"); i != -1 {
+			low, high = item[:i], item[i+1:]
+			if low == "" {
+				low = "1"
+			}
+			if high == "" {
+				high = "65535"
+			}
+		}
+		lPort, err := strconv.ParseUint(low, 10, 16)
+		if err != nil {
+			return s, err
+		}
+		hPort, err := strconv.ParseUint(high, 10, 16)
+		if err != nil {
+			return s, err
+		}
+
+		var ports []uint16
+		for port := lPort; port <= hPort; port++ {
+			ports = append(ports, uint16(port))
+		}
+		switch protocol {
+		case "":
+			s = s.AddPorts(ports...)
+		case "T":
+			s = s.AddTCPPorts(ports...)
+		case "U":
+			s = s.AddUDPPorts(ports...)
+		default:
+			return s, errors.New("Port protocol '" + protocol + "' is not supported")
+		}
+	}
+	return s, nil
+}
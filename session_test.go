@@ -0,0 +1,87 @@
+package nmap
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScan_Record(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	dir, err := ioutil.TempDir("", "nmap-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "scan.tar.gz")
+
+	script := `echo 'Warning: test warning' >&2; echo '<nmaprun args="nmap localhost" version="7.94">` +
+		`<host><status state="up"/><address addr="127.0.0.1" addrtype="ipv4"/>` +
+		`<ports><port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port></ports>` +
+		`</host></nmaprun>'`
+	runner := ExecRunner{
+		Path:   script,
+		Prefix: []string{"sh", "-c"},
+		Env:    []string{"PATH=" + os.Getenv("PATH"), "API_TOKEN=hunter2"},
+	}
+
+	recorded, err := Init().
+		AddHosts("localhost").
+		AddPorts(22).
+		AddUDPPorts(53).
+		AddFlags("--max-retries", "2").
+		SetPrivileges(Privileges{Root: true}).
+		SetRunner(runner).
+		Record(archive).
+		Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := LoadSession(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.NmapVersion != "7.94" || !strings.Contains(string(session.Stderr), "test warning") {
+		t.Errorf("Session was not recorded: %+v", session)
+	}
+	for _, variable := range session.Env {
+		if strings.HasPrefix(variable, "API_TOKEN") {
+			t.Errorf("Sensitive variable was recorded")
+		}
+	}
+
+	replayed, err := Replay(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ToString() != recorded.ToString() || replayed.NmapVersion != recorded.NmapVersion {
+		t.Errorf("Replayed scan is different:\n%s\n%s", replayed.ToString(), recorded.ToString())
+	}
+
+	recordedArgs, _ := recorded.CreateNmapArgs()
+	replayedArgs, _ := replayed.CreateNmapArgs()
+	if strings.Join(recordedArgs, " ") != strings.Join(replayedArgs, " ") {
+		t.Errorf("Replayed configuration is different:\n%v\n%v", replayedArgs, recordedArgs)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	scan, err := ParseArgs([]string{"-oX", "-", "-sS", "--max-retries", "2", "-p1-3,U:53,T:80", "10.0.0.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := scan.SetPrivileges(Privileges{Root: true}).CreateNmapArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "-oX - -sS --max-retries 2 -sU -p1,2,3,U:53,T:80 10.0.0.0/24"
+	if strings.Join(args, " ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(args, " "))
+	}
+}
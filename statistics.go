@@ -0,0 +1,224 @@
+package nmap
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default options for Statistics
+const (
+	DefaultTopPorts = 10
+	DefaultSubnetV4 = 24
+	DefaultSubnetV6 = 64
+)
+
+// unknownStatistics is counted for hosts and ports without a value
+const unknownStatistics = "unknown"
+
+// StatisticsOptions configures Statistics. Zero values use the defaults.
+type StatisticsOptions struct {
+	// TopPorts is the number of ports in Statistics.TopPorts
+	TopPorts int
+	// SubnetV4 and SubnetV6 are the prefix lengths hosts are grouped by
+	SubnetV4 int
+	SubnetV6 int
+}
+
+// Count is the number of hosts or ports with a value
+type Count struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PortCount is the number of hosts with a port open
+type PortCount struct {
+	Protocol string `json:"protocol"`
+	Port     uint32 `json:"port"`
+	// Service is the service found most often on the port
+	Service string `json:"service"`
+	Hosts   int    `json:"hosts"`
+}
+
+// ExposureMatrix is the number of hosts in each subnet with each port open.
+// Hosts[i][j] is the number of hosts in Subnets[i] with Ports[j] open.
+type ExposureMatrix struct {
+	Subnets []string `json:"subnets"`
+	// Ports are the open ports, E.x. `22/tcp`, sorted by protocol and number
+	Ports []string `json:"ports"`
+	Hosts [][]int  `json:"hosts"`
+}
+
+// Statistics are totals over the hosts of a Scan. Counts are sorted from most
+// to least, and then by value.
+type Statistics struct {
+	Hosts     int `json:"hosts"`
+	HostsUp   int `json:"hosts_up"`
+	OpenPorts int `json:"open_ports"`
+	// Services is the number of open ports running each service
+	Services []Count `json:"services"`
+	// TopPorts are the ports open on the most hosts
+	TopPorts []PortCount `json:"top_ports"`
+	// OSFamilies is the number of hosts in each OS family. Hosts without OS
+	// detection results are counted as `unknown`.
+	OSFamilies []Count `json:"os_families"`
+	// Subnets is the number of hosts that are up in each subnet
+	Subnets  []Count        `json:"subnets"`
+	Exposure ExposureMatrix `json:"exposure"`
+}
+
+// Statistics counts open ports, services, OS families and subnets across the
+// scan's hosts. Ports are open when their state is `open` or `open|filtered`.
+// Only hosts that are up are counted, except in Hosts.
+func (s Scan) Statistics(opts StatisticsOptions) Statistics {
+	if opts.TopPorts <= 0 {
+		opts.TopPorts = DefaultTopPorts
+	}
+	if opts.SubnetV4 <= 0 {
+		opts.SubnetV4 = DefaultSubnetV4
+	}
+	if opts.SubnetV6 <= 0 {
+		opts.SubnetV6 = DefaultSubnetV6
+	}
+
+	stats := Statistics{Hosts: len(s.Hosts)}
+	services := make(map[string]int)
+	families := make(map[string]int)
+	subnets := make(map[string]int)
+	portHosts := make(map[PortKey]int)
+	portServices := make(map[PortKey]map[string]int)
+	exposure := make(map[string]map[PortKey]int)
+
+	for _, address := range sortedAddresses(s.Hosts) {
+		host := s.Hosts[address]
+		if host.State != "up" {
+			continue
+		}
+		stats.HostsUp++
+
+		family := host.OSFamily()
+		if family == "" {
+			family = unknownStatistics
+		}
+		families[family]++
+
+		subnet := hostSubnet(address, opts)
+		subnets[subnet]++
+		if exposure[subnet] == nil {
+			exposure[subnet] = make(map[PortKey]int)
+		}
+
+		for _, port := range host.Ports {
+			if !isOpen(port.State) {
+				continue
+			}
+			stats.OpenPorts++
+			key := port.key()
+			portHosts[key]++
+			exposure[subnet][key]++
+
+			service := port.Service
+			if service == "" {
+				service = unknownStatistics
+			}
+			services[service]++
+			if portServices[key] == nil {
+				portServices[key] = make(map[string]int)
+			}
+			portServices[key][service]++
+		}
+	}
+
+	stats.Services = sortCounts(services, nil)
+	stats.OSFamilies = sortCounts(families, nil)
+	stats.Subnets = sortCounts(subnets, subnetLess)
+
+	for key, hosts := range portHosts {
+		service := ""
+		if counts := sortCounts(portServices[key], nil); len(counts) != 0 {
+			service = counts[0].Value
+		}
+		stats.TopPorts = append(stats.TopPorts, PortCount{key.Protocol, key.ID, service, hosts})
+	}
+	sort.Slice(stats.TopPorts, func(i, j int) bool {
+		a, b := stats.TopPorts[i], stats.TopPorts[j]
+		if a.Hosts != b.Hosts {
+			return a.Hosts > b.Hosts
+		}
+		return portKeyLess(PortKey{a.Protocol, a.Port}, PortKey{b.Protocol, b.Port})
+	})
+	if len(stats.TopPorts) > opts.TopPorts {
+		stats.TopPorts = stats.TopPorts[:opts.TopPorts]
+	}
+
+	stats.Exposure = exposureMatrix(exposure, portHosts)
+	return stats
+}
+
+// exposureMatrix builds the matrix from the counts of each port in each subnet
+func exposureMatrix(exposure map[string]map[PortKey]int, portHosts map[PortKey]int) ExposureMatrix {
+	matrix := ExposureMatrix{Subnets: []string{}, Ports: []string{}, Hosts: [][]int{}}
+
+	keys := make([]PortKey, 0, len(portHosts))
+	for key := range portHosts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return portKeyLess(keys[i], keys[j]) })
+	for _, key := range keys {
+		matrix.Ports = append(matrix.Ports, strconv.Itoa(int(key.ID))+"/"+key.Protocol)
+	}
+
+	for subnet := range exposure {
+		matrix.Subnets = append(matrix.Subnets, subnet)
+	}
+	sort.Slice(matrix.Subnets, func(i, j int) bool { return subnetLess(matrix.Subnets[i], matrix.Subnets[j]) })
+	for _, subnet := range matrix.Subnets {
+		row := make([]int, len(keys))
+		for j, key := range keys {
+			row[j] = exposure[subnet][key]
+		}
+		matrix.Hosts = append(matrix.Hosts, row)
+	}
+	return matrix
+}
+
+// hostSubnet returns the subnet of an address, E.x. `10.0.0.0/24`. Addresses
+// that aren't IPs are their own subnet.
+func hostSubnet(address string, opts StatisticsOptions) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(opts.SubnetV4, 32)
+		return (&net.IPNet{IP: ip4.Mask(mask), Mask: mask}).String()
+	}
+	mask := net.CIDRMask(opts.SubnetV6, 128)
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+// subnetLess compares two subnets by their network address
+func subnetLess(a, b string) bool {
+	a, b = strings.SplitN(a, "/", 2)[0], strings.SplitN(b, "/", 2)[0]
+	return addressLess(a, b)
+}
+
+// sortCounts turns counts into a list sorted from most to least. Ties are
+// sorted with less, or by string when less is nil.
+func sortCounts(counts map[string]int, less func(a, b string) bool) []Count {
+	if less == nil {
+		less = func(a, b string) bool { return a < b }
+	}
+	list := make([]Count, 0, len(counts))
+	for value, count := range counts {
+		list = append(list, Count{value, count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return less(list[i].Value, list[j].Value)
+	})
+	return list
+}
@@ -0,0 +1,49 @@
+package nmap
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestScan_Statistics(t *testing.T) {
+	scan := filterScan()
+	scan.Hosts["10.0.0.3"] = Host{Address: "10.0.0.3", State: "up",
+		Ports: []Port{{Protocol: "tcp", ID: 443, State: "open", Service: "https"}}}
+	scan.Hosts["10.0.0.4"] = Host{Address: "10.0.0.4", State: "down"}
+
+	stats := scan.Statistics(StatisticsOptions{TopPorts: 2})
+	if stats.Hosts != 5 || stats.HostsUp != 4 || stats.OpenPorts != 5 {
+		t.Errorf("Unexpected totals: %+v", stats)
+	}
+
+	expectedServices := []Count{{"https", 3}, {"domain", 1}, {"ssh", 1}}
+	if !reflect.DeepEqual(stats.Services, expectedServices) {
+		t.Errorf("Expected services %v, got %v", expectedServices, stats.Services)
+	}
+	expectedTop := []PortCount{{"tcp", 443, "https", 3}, {"tcp", 22, "ssh", 1}}
+	if !reflect.DeepEqual(stats.TopPorts, expectedTop) {
+		t.Errorf("Expected top ports %v, got %v", expectedTop, stats.TopPorts)
+	}
+	expectedFamilies := []Count{{"unknown", 2}, {"Linux", 1}, {"Windows", 1}}
+	if !reflect.DeepEqual(stats.OSFamilies, expectedFamilies) {
+		t.Errorf("Expected OS families %v, got %v", expectedFamilies, stats.OSFamilies)
+	}
+	expectedSubnets := []Count{{"10.0.0.0/24", 3}, {"10.0.1.0/24", 1}}
+	if !reflect.DeepEqual(stats.Subnets, expectedSubnets) {
+		t.Errorf("Expected subnets %v, got %v", expectedSubnets, stats.Subnets)
+	}
+
+	expectedExposure := ExposureMatrix{
+		Subnets: []string{"10.0.0.0/24", "10.0.1.0/24"},
+		Ports:   []string{"22/tcp", "443/tcp", "53/udp"},
+		Hosts:   [][]int{{1, 3, 0}, {0, 0, 1}},
+	}
+	if !reflect.DeepEqual(stats.Exposure, expectedExposure) {
+		t.Errorf("Expected exposure %+v, got %+v", expectedExposure, stats.Exposure)
+	}
+
+	if _, err := json.Marshal(stats); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,290 @@
+package nmap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Version is an nmap release version, E.x. 7.94 or 7.94SVN
+type Version struct {
+	Major int
+	Minor int
+	// Suffix is anything after the version number, such as "SVN" or "BETA1"
+	Suffix string
+}
+
+var versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(\S*)$`)
+
+// ParseVersion parses an nmap version such as "7.94SVN"
+func ParseVersion(version string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(version))
+	if m == nil {
+		return Version{}, errors.New("Could not parse nmap version '" + version + "'")
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return Version{major, minor, m[3]}, nil
+}
+
+// mustParseVersion is used for versions in the option table
+func mustParseVersion(version string) Version {
+	v, err := ParseVersion(version)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// AtLeast returns true when v is the same as or newer than other. Suffixes are
+// ignored, so 7.94SVN is at least 7.94.
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	return v.Minor >= other.Minor
+}
+
+// String returns the version as nmap prints it
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%02d%s", v.Major, v.Minor, v.Suffix)
+}
+
+// NmapInfo is what `nmap --version` reports about an nmap binary
+type NmapInfo struct {
+	Version  Version
+	Platform string
+	// CompiledWith are the libraries nmap was built with, E.x. "openssl-3.0.13"
+	CompiledWith []string
+	// CompiledWithout are the optional libraries nmap was built without
+	CompiledWithout []string
+	NsockEngines    []string
+}
+
+// Library returns the version of a library nmap was compiled with. The second
+// return value is false when nmap was compiled without it. Library names are
+// the ones nmap uses, such as "openssl", "libssh2", "liblua" or "libpcre2".
+// Libraries bundled with nmap are found with or without their `nmap-` prefix,
+// E.x. "liblua" finds "nmap-liblua-5.4.4".
+func (info *NmapInfo) Library(name string) (string, bool) {
+	for _, lib := range info.CompiledWith {
+		for _, candidate := range []string{lib, strings.TrimPrefix(lib, "nmap-")} {
+			if candidate == name {
+				return "", true
+			}
+			if strings.HasPrefix(candidate, name+"-") {
+				return candidate[len(name)+1:], true
+			}
+		}
+	}
+	return "", false
+}
+
+// OpenSSL returns true when nmap was built with OpenSSL, which is needed for
+// SSL service detection and the ssl-* scripts
+func (info *NmapInfo) OpenSSL() bool {
+	_, ok := info.Library("openssl")
+	return ok
+}
+
+// LibSSH2 returns true when nmap was built with libssh2, which is needed for
+// the ssh-* scripts that log in
+func (info *NmapInfo) LibSSH2() bool {
+	_, ok := info.Library("libssh2")
+	return ok
+}
+
+// NSE returns true when nmap was built with Lua, which is needed to run NSE
+// scripts
+func (info *NmapInfo) NSE() bool {
+	_, ok := info.Library("liblua")
+	return ok
+}
+
+var versionLine = regexp.MustCompile(`^Nmap version (\S+)`)
+
+// ParseNmapInfo parses the output of `nmap --version`
+func ParseNmapInfo(output string) (*NmapInfo, error) {
+	info := &NmapInfo{}
+	found := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := versionLine.FindStringSubmatch(line); m != nil {
+			version, err := ParseVersion(m[1])
+			if err != nil {
+				return nil, err
+			}
+			info.Version = version
+			found = true
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		switch key {
+		case "Platform":
+			info.Platform = value
+		case "Compiled with":
+			info.CompiledWith = strings.Fields(value)
+		case "Compiled without":
+			info.CompiledWithout = strings.Fields(value)
+		case "Available nsock engines":
+			info.NsockEngines = strings.Fields(value)
+		}
+	}
+
+	if !found {
+		return nil, errors.New("Could not find the nmap version in the output of `nmap --version`")
+	}
+	return info, nil
+}
+
+// versionCache holds the NmapInfo for each nmap binary
+var versionCache = struct {
+	sync.Mutex
+	info map[string]*NmapInfo
+}{info: make(map[string]*NmapInfo)}
+
+// DetectNmapInfo runs `nmap --version` with the Runner and parses the output.
+// The result is cached for each nmap binary run by an ExecRunner, so nmap is
+// only run once per binary.
+func DetectNmapInfo(runner Runner) (*NmapInfo, error) {
+	key := ""
+	if execRunner, ok := runner.(ExecRunner); ok {
+		key = execRunner.cacheKey()
+		versionCache.Lock()
+		info, ok := versionCache.info[key]
+		versionCache.Unlock()
+		if ok {
+			return info, nil
+		}
+	}
+
+	process, err := runner.Start([]string{"--version"})
+	if err != nil {
+		return nil, err
+	}
+	stderr := make(chan []byte, 1)
+	go func() {
+		data, _ := ioutil.ReadAll(process.Stderr())
+		stderr <- data
+	}()
+	stdout, err := ioutil.ReadAll(io.LimitReader(process.Stdout(), 64*1024))
+	errOut := <-stderr
+	if err != nil {
+		return nil, err
+	}
+	exitCode, err := process.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, exitError(exitCode, errOut, nil)
+	}
+
+	info, err := ParseNmapInfo(string(stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		versionCache.Lock()
+		versionCache.info[key] = info
+		versionCache.Unlock()
+	}
+	return info, nil
+}
+
+// NmapInfo returns the information about the nmap that the scan will run.
+// SetNmapInfo can be used to set it without running nmap.
+func (s Scan) NmapInfo() (*NmapInfo, error) {
+	if s.configNmapInfo != nil {
+		return s.configNmapInfo, nil
+	}
+	return DetectNmapInfo(s.runner())
+}
+
+// SetNmapInfo makes Validate check options against what the nmap binary
+// supports. Options that need a newer nmap, or a library nmap wasn't built
+// with, are reported as UnsupportedOptionErrors.
+func (s Scan) SetNmapInfo(info *NmapInfo) Scan {
+	s.configNmapInfo = info
+	return s
+}
+
+// AdaptTo removes the optional flags that the nmap binary doesn't support and
+// then makes Validate check the remaining options with SetNmapInfo. Optional
+// flags are ones that only tune how nmap scans, such as
+// `--defeat-icmp-ratelimit`.
+func (s Scan) AdaptTo(info *NmapInfo) Scan {
+	flags, _ := parseFlags(s.configOpts)
+
+	// Only the unsupported flags are removed, so flags that can't be parsed are
+	// kept for Validate to report
+	var names []string
+	for _, f := range flags {
+		if f.option.Optional && unsupported(f.option, info) != "" {
+			names = append(names, f.option.Name)
+		}
+	}
+	if len(names) != 0 {
+		s.configOpts, _ = withoutFlags(s.configOpts, names...)
+	}
+	return s.SetNmapInfo(info)
+}
+
+// UnsupportedOptionError is returned by Validate when the nmap binary doesn't
+// support an option
+type UnsupportedOptionError struct {
+	Flag   string
+	Reason string
+}
+
+// Error returns the flag and why it isn't supported
+func (u *UnsupportedOptionError) Error() string {
+	return "Flag '" + u.Flag + "' is not supported: " + u.Reason
+}
+
+// unsupported returns why the nmap binary can't use the option, or an empty
+// string if it can
+func unsupported(option nmapOption, info *NmapInfo) string {
+	if option.MinVersion != "" {
+		min := mustParseVersion(option.MinVersion)
+		if !info.Version.AtLeast(min) {
+			return "it needs nmap " + min.String() + " or newer, but nmap is " + info.Version.String()
+		}
+	}
+	if option.NSE && !info.NSE() {
+		return "nmap was built without Lua, so NSE scripts can't be run"
+	}
+	return ""
+}
+
+// checkNmapInfo returns an UnsupportedOptionError for each option that the nmap
+// binary doesn't support
+func (s Scan) checkNmapInfo(flags []parsedFlag) (errs []error) {
+	info := s.configNmapInfo
+	if info == nil {
+		return nil
+	}
+	for _, f := range flags {
+		if reason := unsupported(f.option, info); reason != "" {
+			errs = append(errs, &UnsupportedOptionError{f.flag, reason})
+		}
+	}
+	if len(s.scriptArgs()) != 0 && !info.NSE() {
+		errs = append(errs, &UnsupportedOptionError{"--script", "nmap was built without Lua, so NSE scripts can't be run"})
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package nmap
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/t94j0/array"
+)
+
+const testVersionOutput = `Nmap version 7.94SVN ( https://nmap.org )
+Platform: x86_64-pc-linux-gnu
+Compiled with: liblua-5.4.6 openssl-3.0.13 libssh2-1.11.0 libz-1.3 libpcre2-10.42 libpcap-1.10.4 nmap-libdnet-1.12 ipv6
+Compiled without:
+Available nsock engines: epoll poll select
+`
+
+func TestParseNmapInfo(t *testing.T) {
+	info, err := ParseNmapInfo(testVersionOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != (Version{7, 94, "SVN"}) || info.Version.String() != "7.94SVN" {
+		t.Errorf("Wrong version: %v", info.Version)
+	}
+	if info.Platform != "x86_64-pc-linux-gnu" || len(info.NsockEngines) != 3 {
+		t.Errorf("Wrong info: %+v", info)
+	}
+	if version, ok := info.Library("openssl"); !ok || version != "3.0.13" {
+		t.Errorf("Wrong OpenSSL version: %s", version)
+	}
+	if !info.NSE() || !info.LibSSH2() {
+		t.Errorf("Lua and libssh2 should be found")
+	}
+}
+
+// testOfficialVersionOutput is from the nmap.org build, which bundles its
+// libraries with an `nmap-` prefix
+const testOfficialVersionOutput = `Nmap version 7.94 ( https://nmap.org )
+Platform: x86_64-pc-linux-gnu
+Compiled with: nmap-liblua-5.4.4 openssl-3.0.13 nmap-libssh2-1.10.0 nmap-libz-1.2.13 libpcre2-10.42 nmap-libpcap-1.10.4 nmap-libdnet-1.12 ipv6
+Compiled without:
+Available nsock engines: epoll poll select
+`
+
+func TestParseNmapInfo_official(t *testing.T) {
+	info, err := ParseNmapInfo(testOfficialVersionOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.NSE() || !info.LibSSH2() {
+		t.Errorf("Bundled Lua and libssh2 should be found")
+	}
+	if version, ok := info.Library("liblua"); !ok || version != "5.4.4" {
+		t.Errorf("Wrong Lua version: %s", version)
+	}
+	if _, ok := info.Library("nmap-libdnet"); !ok {
+		t.Errorf("Libraries should also be found by their full name")
+	}
+	if err := Init().AddHosts("localhost").AddFlags("-sC").SetNmapInfo(info).Validate(); err != nil {
+		t.Errorf("Scripts should be supported: %v", err)
+	}
+}
+
+func TestScan_SetNmapInfo(t *testing.T) {
+	old := &NmapInfo{Version: Version{7, 60, ""}}
+
+	scan := Init().AddHosts("localhost").AddFlags("--defeat-icmp-ratelimit", "--resolve-all", "-sC")
+	err := scan.SetNmapInfo(old).Validate()
+	var unsupported *UnsupportedOptionError
+	if !errors.As(err, &unsupported) || unsupported.Flag != "--defeat-icmp-ratelimit" {
+		t.Errorf("Expected --defeat-icmp-ratelimit to be unsupported: %v", err)
+	}
+	if errs, ok := err.(ConfigErrors); !ok || len(errs) != 3 {
+		t.Errorf("Expected 3 unsupported flags, got %v", err)
+	}
+
+	adapted := scan.AdaptTo(old)
+	args, _ := adapted.SetPrivileges(Privileges{}).CreateNmapArgs()
+	if array.In("--defeat-icmp-ratelimit", args) {
+		t.Errorf("Optional flag should be removed: %v", args)
+	}
+	if errs, ok := adapted.Validate().(ConfigErrors); !ok || len(errs) != 2 {
+		t.Errorf("Flags that aren't optional should still be reported: %v", errs)
+	}
+}
+
+func TestScan_AdaptTo_invalidFlags(t *testing.T) {
+	old := &NmapInfo{Version: Version{7, 60, ""}}
+	scan := Init().AddHosts("localhost")
+	scan.configOpts = []string{"--defeat-icmp-ratelimit", "--not-a-flag", "-T4"}
+
+	adapted := scan.AdaptTo(old)
+	if strings.Join(adapted.configOpts, " ") != "--not-a-flag -T4" {
+		t.Errorf("Expected only the unsupported flag to be removed, got %q", adapted.configOpts)
+	}
+	var flagErr *FlagError
+	if err := adapted.Validate(); !errors.As(err, &flagErr) || flagErr.Flag != "--not-a-flag" {
+		t.Errorf("Expected the unknown flag to be reported, got %v", err)
+	}
+}
+
+func TestDetectNmapInfo(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	runner := ExecRunner{Path: "printf '" + testVersionOutput + "'", Prefix: []string{"sh", "-c"}}
+	info, err := DetectNmapInfo(runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version.Minor != 94 {
+		t.Errorf("Wrong version: %v", info.Version)
+	}
+	if cached, _ := DetectNmapInfo(runner); cached != info {
+		t.Errorf("Info should be cached for the binary")
+	}
+}
@@ -0,0 +1,84 @@
+package nmap
+
+import (
+	"regexp"
+	"strings"
+)
+
+// WarningKind is the type of a warning that nmap printed
+type WarningKind string
+
+// Kinds of warnings that are recognized in nmap's stderr
+const (
+	// WarningUnresolved is printed when a target's hostname can't be resolved
+	WarningUnresolved WarningKind = "unresolved"
+	// WarningRequiresRoot is printed when a feature needs root privileges
+	WarningRequiresRoot WarningKind = "requires-root"
+	// WarningRTTVar is printed when the round trip time varies too much
+	WarningRTTVar WarningKind = "rttvar"
+	// WarningHostTimeout is printed when a host is skipped by `--host-timeout`
+	WarningHostTimeout WarningKind = "host-timeout"
+	// WarningUnknownOption is printed when nmap doesn't understand an option
+	WarningUnknownOption WarningKind = "unknown-option"
+	// WarningOther is any other line starting with `Warning:`
+	WarningOther WarningKind = "other"
+)
+
+// Warning is a warning that nmap printed to stderr. Host is set when the
+// warning is about a single target. Option is set for WarningUnknownOption.
+type Warning struct {
+	Kind    WarningKind
+	Host    string
+	Option  string
+	Message string
+}
+
+// warningPatterns match a line of stderr to the kind of warning. The first
+// submatch is the host or option the warning is about.
+var warningPatterns = []struct {
+	kind    WarningKind
+	pattern *regexp.Regexp
+}{
+	{WarningUnresolved, regexp.MustCompile(`^Failed to resolve "([^"]*)"`)},
+	{WarningRequiresRoot, regexp.MustCompile(`requires root privileges()`)},
+	{WarningRTTVar, regexp.MustCompile(`^RTTVAR has grown to over()`)},
+	{WarningHostTimeout, regexp.MustCompile(`^Skipping host (\S+).* due to host timeout`)},
+	{WarningUnknownOption, regexp.MustCompile(`^nmap: unrecognized option '([^']*)'`)},
+	{WarningUnknownOption, regexp.MustCompile(`^nmap: option '([^']*)' requires an argument`)},
+	{WarningUnknownOption, regexp.MustCompile(`^nmap: option requires an argument -- '(.)'`)},
+	{WarningUnknownOption, regexp.MustCompile(`^nmap: invalid option -- '(.)'`)},
+	{WarningUnknownOption, regexp.MustCompile(`^Unknown argument to (-\S+)`)},
+	{WarningOther, regexp.MustCompile(`(?i)^warning:()`)},
+}
+
+// parseWarning converts a line of nmap's stderr into a Warning. The second
+// return value is false when the line isn't a warning.
+func parseWarning(line string) (Warning, bool) {
+	line = strings.TrimSpace(line)
+	for _, p := range warningPatterns {
+		m := p.pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		warning := Warning{Kind: p.kind, Message: line}
+		switch p.kind {
+		case WarningUnresolved, WarningHostTimeout:
+			warning.Host = m[1]
+		case WarningUnknownOption:
+			warning.Option = m[1]
+		}
+		return warning, true
+	}
+	return Warning{}, false
+}
+
+// hasWarning returns the first warning of the kind
+func hasWarning(warnings []Warning, kind WarningKind) (Warning, bool) {
+	for _, warning := range warnings {
+		if warning.Kind == kind {
+			return warning, true
+		}
+	}
+	return Warning{}, false
+}
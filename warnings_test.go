@@ -0,0 +1,83 @@
+package nmap
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestParseWarning(t *testing.T) {
+	tests := []struct {
+		line   string
+		kind   WarningKind
+		host   string
+		option string
+	}{
+		{`Failed to resolve "no-such-host.invalid".`, WarningUnresolved, "no-such-host.invalid", ""},
+		{`You requested a scan type which requires root privileges.`, WarningRequiresRoot, "", ""},
+		{`TCP/IP fingerprinting (for OS scan) requires root privileges.`, WarningRequiresRoot, "", ""},
+		{`RTTVAR has grown to over 2.3 seconds, decreasing to 2.0`, WarningRTTVar, "", ""},
+		{`Skipping host 10.0.0.1 due to host timeout`, WarningHostTimeout, "10.0.0.1", ""},
+		{`nmap: unrecognized option '--bogus'`, WarningUnknownOption, "", "--bogus"},
+		{`nmap: option requires an argument -- 'p'`, WarningUnknownOption, "", "p"},
+		{`Warning: 10.0.0.1 giving up on port because retransmission cap hit (10).`, WarningOther, "", ""},
+	}
+
+	for _, test := range tests {
+		warning, ok := parseWarning(test.line)
+		if !ok {
+			t.Errorf("%q was not parsed", test.line)
+			continue
+		}
+		if warning.Kind != test.kind || warning.Host != test.host || warning.Option != test.option {
+			t.Errorf("%q was parsed as %+v", test.line, warning)
+		}
+	}
+
+	if _, ok := parseWarning("Starting Nmap 7.94 ( https://nmap.org )"); ok {
+		t.Errorf("Banner was parsed as a warning")
+	}
+}
+
+func TestScan_Run_typederrors(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	run := func(script string) (Scan, error) {
+		runner := ExecRunner{Path: script, Prefix: []string{"sh", "-c"}}
+		return Init().AddHosts("localhost").SetRunner(runner).Run()
+	}
+
+	_, err := run(`echo "nmap: unrecognized option '--bogus'" >&2; exit 255`)
+	var invalidArg *InvalidArgumentError
+	var exitErr *ExitError
+	if !errors.As(err, &invalidArg) || invalidArg.Option != "--bogus" {
+		t.Errorf("Expected an InvalidArgumentError, got %v", err)
+	}
+	if !errors.As(err, &exitErr) || exitErr.Code != 255 {
+		t.Errorf("InvalidArgumentError should wrap an ExitError: %v", err)
+	}
+
+	_, err = run(`echo "You requested a scan type which requires root privileges." >&2; echo "QUITTING!" >&2; exit 1`)
+	var privilegeErr *PrivilegeError
+	if !errors.As(err, &privilegeErr) {
+		t.Errorf("Expected a PrivilegeError, got %v", err)
+	}
+
+	_, err = run(`echo "sh: nmap: not found" >&2; exit 127`)
+	if !errors.Is(err, ErrNmapNotFound) {
+		t.Errorf("Expected ErrNmapNotFound, got %v", err)
+	}
+	if !errors.As(err, &exitErr) || exitErr.Code != 127 || !strings.Contains(exitErr.Stderr, "not found") {
+		t.Errorf("ErrNmapNotFound should keep the ExitError: %v", err)
+	}
+
+	scan, err := run(`echo 'Failed to resolve "nowhere.invalid".' >&2; echo '<nmaprun></nmaprun>'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.Warnings) != 1 || scan.Warnings[0].Host != "nowhere.invalid" {
+		t.Errorf("Warnings were not added to the scan: %+v", scan.Warnings)
+	}
+}